@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/candidates"
+	"github.com/videocoin/cloud-miners/datastore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator returns the ID of the admin calling with the bearer token
+// of the context metadata, or a gRPC status error.
+type Authenticator func(ctx context.Context) (string, error)
+
+// API serves the operations reserved to ops under /admin. Every route
+// requires the bearer token of an admin, checked the same way as on the gRPC
+// API.
+type API struct {
+	logger       *logrus.Entry
+	ds           *datastore.Datastore
+	candidates   *candidates.Engine
+	authenticate Authenticator
+}
+
+func NewAPI(opts ...Option) (*API, error) {
+	a := &API{}
+	for _, o := range opts {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (a *API) Register(e *echo.Echo) {
+	g := e.Group("/admin", a.auth)
+	g.POST("/candidates/explain", a.explainCandidates)
+	g.GET("/miners/:id/incidents", a.listIncidents)
+	g.GET("/miners/:id/commands", a.listCommands)
+	g.POST("/miners/:id/commands", a.enqueueCommand)
+	g.GET("/miners/:id/blocks", a.listBlocks)
+	g.POST("/miners/:id/block", a.block)
+	g.POST("/miners/:id/unblock", a.unblock)
+	g.PUT("/miners/:id/release-channel", a.setReleaseChannel)
+	g.GET("/internal-miners", a.listInternalMiners)
+	g.GET("/rollouts", a.listRollouts)
+	g.POST("/rollouts", a.createRollout)
+	g.PUT("/rollouts/:id", a.updateRollout)
+	g.DELETE("/rollouts/:id", a.deactivateRollout)
+	g.GET("/release-channel-selectors", a.listChannelSelectors)
+	g.POST("/release-channel-selectors", a.createChannelSelector)
+	g.GET("/config-profiles", a.listProfiles)
+	g.POST("/config-profiles", a.createProfile)
+	g.PUT("/config-profiles/:name", a.updateProfile)
+	g.DELETE("/config-profiles/:name", a.deleteProfile)
+	g.GET("/config-profiles/:name/assignments", a.listAssignments)
+	g.POST("/config-profiles/:name/assignments", a.assignProfile)
+	g.DELETE("/config-profile-assignments/:id", a.unassignProfile)
+}
+
+// auth hands the Authorization header to the authenticator as gRPC metadata
+// and keeps the admin ID in the echo context.
+func (a *API) auth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		md := metadata.Pairs("authorization", c.Request().Header.Get(echo.HeaderAuthorization))
+		ctx := metadata.NewIncomingContext(c.Request().Context(), md)
+
+		adminID, err := a.authenticate(ctx)
+		if err != nil {
+			if status.Code(err) == codes.Unauthenticated {
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication failed")
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "permission denied")
+		}
+
+		c.Set("admin_id", adminID)
+
+		return next(c)
+	}
+}
+
+func adminID(c echo.Context) string {
+	id, _ := c.Get("admin_id").(string)
+	return id
+}
+
+func (a *API) getMiner(c echo.Context) (*datastore.Miner, error) {
+	miner, err := a.ds.Miners.Get(c.Request().Context(), c.Param("id"), "")
+	if err != nil {
+		if err == datastore.ErrMinerNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return miner, nil
+}
@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+// blockRequest blocks a miner for TTLSeconds, or until unblocked when it is
+// zero.
+type blockRequest struct {
+	Reason     string `json:"reason"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+type blocksResponse struct {
+	Items []*datastore.Block `json:"items"`
+}
+
+func (a *API) block(c echo.Context) error {
+	req := &blockRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Reason == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "reason is required")
+	}
+
+	miner, err := a.getMiner(c)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	block, err := a.ds.Miners.Block(c.Request().Context(), miner, req.Reason, adminID(c), ttl)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to block miner")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, block)
+}
+
+func (a *API) unblock(c echo.Context) error {
+	miner, err := a.getMiner(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Miners.Unblock(c.Request().Context(), miner, adminID(c)); err != nil {
+		a.logger.WithError(err).Error("failed to unblock miner")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listBlocks returns the block history of a miner.
+func (a *API) listBlocks(c echo.Context) error {
+	items, err := a.ds.Miners.ListBlocks(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list blocks")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &blocksResponse{Items: items})
+}
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+)
+
+type commandsResponse struct {
+	Items []*datastore.Command `json:"items"`
+}
+
+// enqueueCommand enqueues a command of any type on a miner.
+func (a *API) enqueueCommand(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	req := &ext.CommandRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	commandType, err := datastore.ParseCommandType(req.Type)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	miner, err := a.getMiner(c)
+	if err != nil {
+		return err
+	}
+
+	ttl := datastore.DefaultCommandTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	command, err := a.ds.Commands.Enqueue(ctx, miner.ID, adminID(c), commandType, datastore.Info(req.Payload), ttl)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to enqueue command")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, command)
+}
+
+// listCommands returns the commands of a miner, newest first.
+func (a *API) listCommands(c echo.Context) error {
+	fltr := &datastore.ListFilter{Limit: pointer.ToInt(100)}
+	if limit, err := strconv.Atoi(c.QueryParam("limit")); err == nil && limit > 0 {
+		fltr.Limit = pointer.ToInt(limit)
+	}
+	if offset, err := strconv.Atoi(c.QueryParam("offset")); err == nil && offset > 0 {
+		fltr.Offset = pointer.ToInt(offset)
+	}
+
+	items, err := a.ds.Commands.List(c.Request().Context(), c.Param("id"), fltr)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list commands")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &commandsResponse{Items: items})
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/candidates"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type explainRequest struct {
+	Request *candidates.Request `json:"request"`
+	MinerID string              `json:"miner_id"`
+}
+
+type explainResponse struct {
+	Items []*candidates.Explanation `json:"items"`
+}
+
+// explainCandidates is the dry run of candidate selection.
+func (a *API) explainCandidates(c echo.Context) error {
+	req := &explainRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Request == nil {
+		req.Request = &candidates.Request{}
+	}
+
+	items, err := a.candidates.Explain(c.Request().Context(), req.Request, req.MinerID)
+	if err != nil {
+		if err == datastore.ErrMinerNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		a.logger.WithError(err).Error("failed to explain candidates")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &explainResponse{Items: items})
+}
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AlekSi/pointer"
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type incidentsResponse struct {
+	Items []*datastore.Incident `json:"items"`
+}
+
+// listIncidents returns the remediation incidents of a miner, newest first.
+func (a *API) listIncidents(c echo.Context) error {
+	fltr := &datastore.ListFilter{Limit: pointer.ToInt(100)}
+	if limit, err := strconv.Atoi(c.QueryParam("limit")); err == nil && limit > 0 {
+		fltr.Limit = pointer.ToInt(limit)
+	}
+	if offset, err := strconv.Atoi(c.QueryParam("offset")); err == nil && offset > 0 {
+		fltr.Offset = pointer.ToInt(offset)
+	}
+
+	items, err := a.ds.Incidents.List(c.Request().Context(), c.Param("id"), fltr)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list incidents")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &incidentsResponse{Items: items})
+}
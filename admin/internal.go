@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// internalMiner is an internal miner with the state of its lease.
+type internalMiner struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Status          string     `json:"status"`
+	Leased          bool       `json:"leased"`
+	LeaseHolder     string     `json:"lease_holder,omitempty"`
+	LeaseAcquiredAt *time.Time `json:"lease_acquired_at,omitempty"`
+	LeaseExpiresAt  *time.Time `json:"lease_expires_at,omitempty"`
+}
+
+type internalMinersResponse struct {
+	Items []*internalMiner `json:"items"`
+}
+
+// listInternalMiners is the inventory of the internal miner pool.
+func (a *API) listInternalMiners(c echo.Context) error {
+	miners, err := a.ds.Miners.ListByInternal(c.Request().Context())
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list internal miners")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	items := []*internalMiner{}
+	for _, miner := range miners {
+		items = append(items, &internalMiner{
+			ID:              miner.ID,
+			Name:            miner.Name,
+			Status:          miner.Status.String(),
+			Leased:          miner.IsLeased(),
+			LeaseHolder:     miner.LeaseHolder.String,
+			LeaseAcquiredAt: miner.LeaseAcquiredAt,
+			LeaseExpiresAt:  miner.LeaseExpiresAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &internalMinersResponse{Items: items})
+}
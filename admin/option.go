@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/candidates"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type Option func(*API) error
+
+func WithLogger(logger *logrus.Entry) Option {
+	return func(a *API) error {
+		a.logger = logger
+		return nil
+	}
+}
+
+func WithDatastore(ds *datastore.Datastore) Option {
+	return func(a *API) error {
+		a.ds = ds
+		return nil
+	}
+}
+
+func WithCandidates(ce *candidates.Engine) Option {
+	return func(a *API) error {
+		a.candidates = ce
+		return nil
+	}
+}
+
+func WithAuthenticator(authenticate Authenticator) Option {
+	return func(a *API) error {
+		a.authenticate = authenticate
+		return nil
+	}
+}
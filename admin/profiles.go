@@ -0,0 +1,173 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type profileRequest struct {
+	Name          string         `json:"name"`
+	SchemaVersion int            `json:"schema_version"`
+	Document      datastore.Info `json:"document"`
+}
+
+type profilesResponse struct {
+	Items []*datastore.ConfigProfile `json:"items"`
+}
+
+// assignmentRequest assigns a profile to one of a miner, a user or the
+// miners matching a tag selector.
+type assignmentRequest struct {
+	MinerID  string         `json:"miner_id"`
+	UserID   string         `json:"user_id"`
+	Selector datastore.Tags `json:"selector"`
+	Priority int            `json:"priority"`
+}
+
+type assignmentsResponse struct {
+	Items []*datastore.ConfigProfileAssignment `json:"items"`
+}
+
+func (a *API) getProfile(c echo.Context) (*datastore.ConfigProfile, error) {
+	profile, err := a.ds.Profiles.GetByName(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		if err == datastore.ErrConfigProfileNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return profile, nil
+}
+
+func (a *API) listProfiles(c echo.Context) error {
+	items, err := a.ds.Profiles.List(c.Request().Context())
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list config profiles")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &profilesResponse{Items: items})
+}
+
+func (a *API) createProfile(c echo.Context) error {
+	req := &profileRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Name == "" || req.Document == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "name and document are required")
+	}
+
+	profile, err := a.ds.Profiles.Create(c.Request().Context(), req.Name, req.SchemaVersion, req.Document)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to create config profile")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, profile)
+}
+
+// updateProfile replaces the document of a profile. Agents get the new
+// revision on their next ping.
+func (a *API) updateProfile(c echo.Context) error {
+	req := &profileRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Document == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "document is required")
+	}
+
+	profile, err := a.getProfile(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Profiles.UpdateDocument(c.Request().Context(), profile, req.SchemaVersion, req.Document); err != nil {
+		a.logger.WithError(err).Error("failed to update config profile")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (a *API) deleteProfile(c echo.Context) error {
+	profile, err := a.getProfile(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Profiles.Delete(c.Request().Context(), profile); err != nil {
+		a.logger.WithError(err).Error("failed to delete config profile")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) listAssignments(c echo.Context) error {
+	profile, err := a.getProfile(c)
+	if err != nil {
+		return err
+	}
+
+	items, err := a.ds.Profiles.ListAssignments(c.Request().Context(), profile)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list config profile assignments")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &assignmentsResponse{Items: items})
+}
+
+func (a *API) assignProfile(c echo.Context) error {
+	req := &assignmentRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	targets := 0
+	for _, set := range []bool{req.MinerID != "", req.UserID != "", len(req.Selector) > 0} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "one of miner_id, user_id or selector is required")
+	}
+
+	profile, err := a.getProfile(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var assignment *datastore.ConfigProfileAssignment
+	switch {
+	case req.MinerID != "":
+		assignment, err = a.ds.Profiles.AssignToMiner(ctx, profile, req.MinerID)
+	case req.UserID != "":
+		assignment, err = a.ds.Profiles.AssignToUser(ctx, profile, req.UserID)
+	default:
+		assignment, err = a.ds.Profiles.AssignToSelector(ctx, profile, req.Selector, req.Priority)
+	}
+	if err != nil {
+		a.logger.WithError(err).Error("failed to assign config profile")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, assignment)
+}
+
+func (a *API) unassignProfile(c echo.Context) error {
+	if err := a.ds.Profiles.Unassign(c.Request().Context(), c.Param("id")); err != nil {
+		a.logger.WithError(err).Error("failed to unassign config profile")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,225 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type rolloutRequest struct {
+	Channel       string `json:"channel"`
+	TargetVersion string `json:"target_version"`
+	Percentage    int    `json:"percentage"`
+	Hw            string `json:"hw"`
+}
+
+// rolloutProgress is an active rollout with the number of miners it covers
+// and how many of them already run the target version.
+type rolloutProgress struct {
+	*datastore.Rollout
+	Miners   int `json:"miners"`
+	Upgraded int `json:"upgraded"`
+}
+
+type rolloutsResponse struct {
+	Items []*rolloutProgress `json:"items"`
+}
+
+type channelSelectorRequest struct {
+	Channel  string         `json:"channel"`
+	Selector datastore.Tags `json:"selector"`
+	Priority int            `json:"priority"`
+}
+
+type channelSelectorsResponse struct {
+	Items []*datastore.ReleaseChannelSelector `json:"items"`
+}
+
+type releaseChannelRequest struct {
+	Channel string `json:"channel"`
+}
+
+func (a *API) listRollouts(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	rollouts, err := a.ds.Rollouts.ListActive(ctx)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list rollouts")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	selectors, err := a.ds.Rollouts.ListChannelSelectors(ctx)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list channel selectors")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	miners, err := a.ds.Miners.List(ctx, nil)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list miners")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	items := []*rolloutProgress{}
+	progress := map[string]*rolloutProgress{}
+	for _, rollout := range rollouts {
+		p := &rolloutProgress{Rollout: rollout}
+		progress[rollout.ID] = p
+		items = append(items, p)
+	}
+
+	for _, miner := range miners {
+		channel := datastore.ResolveChannel(miner, selectors)
+		rollout := datastore.ResolveRollout(miner, channel, rollouts)
+		if rollout == nil {
+			continue
+		}
+
+		p := progress[rollout.ID]
+		p.Miners++
+		if miner.Version.String == rollout.TargetVersion {
+			p.Upgraded++
+		}
+	}
+
+	return c.JSON(http.StatusOK, &rolloutsResponse{Items: items})
+}
+
+func (a *API) createRollout(c echo.Context) error {
+	req := &rolloutRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	channel, err := datastore.ParseReleaseChannel(req.Channel)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.TargetVersion == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "target_version is required")
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "percentage must be between 0 and 100")
+	}
+
+	rollout, err := a.ds.Rollouts.Create(c.Request().Context(), channel, req.TargetVersion, req.Percentage, req.Hw)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to create rollout")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, rollout)
+}
+
+func (a *API) getRollout(c echo.Context) (*datastore.Rollout, error) {
+	rollout, err := a.ds.Rollouts.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if err == datastore.ErrRolloutNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return rollout, nil
+}
+
+// updateRollout changes the percentage of a rollout.
+func (a *API) updateRollout(c echo.Context) error {
+	req := &rolloutRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "percentage must be between 0 and 100")
+	}
+
+	rollout, err := a.getRollout(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Rollouts.UpdatePercentage(c.Request().Context(), rollout, req.Percentage); err != nil {
+		a.logger.WithError(err).Error("failed to update rollout")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, rollout)
+}
+
+func (a *API) deactivateRollout(c echo.Context) error {
+	rollout, err := a.getRollout(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Rollouts.Deactivate(c.Request().Context(), rollout); err != nil {
+		a.logger.WithError(err).Error("failed to deactivate rollout")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) listChannelSelectors(c echo.Context) error {
+	items, err := a.ds.Rollouts.ListChannelSelectors(c.Request().Context())
+	if err != nil {
+		a.logger.WithError(err).Error("failed to list channel selectors")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &channelSelectorsResponse{Items: items})
+}
+
+func (a *API) createChannelSelector(c echo.Context) error {
+	req := &channelSelectorRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	channel, err := datastore.ParseReleaseChannel(req.Channel)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.Selector) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "selector is required")
+	}
+
+	cs, err := a.ds.Rollouts.CreateChannelSelector(c.Request().Context(), channel, req.Selector, req.Priority)
+	if err != nil {
+		a.logger.WithError(err).Error("failed to create channel selector")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, cs)
+}
+
+// setReleaseChannel assigns a release channel to a miner. An empty channel
+// hands the miner back to the selectors.
+func (a *API) setReleaseChannel(c echo.Context) error {
+	req := &releaseChannelRequest{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var channel datastore.ReleaseChannel
+	if req.Channel != "" {
+		var err error
+		channel, err = datastore.ParseReleaseChannel(req.Channel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	miner, err := a.getMiner(c)
+	if err != nil {
+		return err
+	}
+
+	if err := a.ds.Miners.UpdateReleaseChannel(c.Request().Context(), miner, channel); err != nil {
+		a.logger.WithError(err).Error("failed to update release channel")
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package candidates
+
+import (
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+)
+
+const (
+	AntiAffinityScopeMiner = ext.AntiAffinityScopeMiner
+	AntiAffinityScopeUser  = ext.AntiAffinityScopeUser
+)
+
+type (
+	Affinity     = ext.Affinity
+	AntiAffinity = ext.AntiAffinity
+)
+
+// colocation holds the miners and owners taken by anti-affinity tasks.
+type colocation struct {
+	scope  string
+	miners map[string]bool
+	users  map[string]bool
+}
+
+func (c *colocation) conflicts(miner *datastore.Miner) bool {
+	if c == nil {
+		return false
+	}
+	if c.scope == AntiAffinityScopeMiner {
+		return c.miners[miner.ID]
+	}
+	return c.users[miner.UserID]
+}
+
+func (e *Engine) checkAffinity(req *Request, c *Candidate) string {
+	a := req.Affinity
+	if a == nil {
+		return ""
+	}
+
+	if a.InternalOnly && !c.Miner.IsInternal {
+		return "miner is not internal"
+	}
+	if !c.Miner.Tags.Match(datastore.Tags(a.RequiredTags)) {
+		return "miner does not have the required tags"
+	}
+	for _, id := range a.ExcludeMiners {
+		if id == c.Miner.ID {
+			return "miner is excluded"
+		}
+	}
+	for _, id := range a.ExcludeUsers {
+		if id == c.Miner.UserID {
+			return "miner owner is excluded"
+		}
+	}
+	if c.colocation.conflicts(c.Miner) {
+		return "anti-affinity with a task on the same " + c.colocation.scope
+	}
+
+	return ""
+}
+
+// preference is the share of preferred tags the miner matches.
+func preference(req *Request, c *Candidate) float64 {
+	tags := req.Affinity.PreferredTags
+	if len(tags) == 0 {
+		return 0
+	}
+
+	matched := 0
+	for k, v := range tags {
+		if c.Miner.Tags[k] == v {
+			matched++
+		}
+	}
+
+	return float64(matched) / float64(len(tags))
+}
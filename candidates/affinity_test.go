@@ -0,0 +1,116 @@
+package candidates
+
+import (
+	"testing"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func TestCheckAffinity(t *testing.T) {
+	miner := &datastore.Miner{
+		ID:     "miner",
+		UserID: "owner",
+		Tags:   datastore.Tags{"hw": "jetson", "region": "eu"},
+	}
+
+	tests := []struct {
+		name       string
+		affinity   *Affinity
+		internal   bool
+		colocation *colocation
+		want       string
+	}{
+		{
+			name:     "no affinity",
+			affinity: nil,
+			want:     "",
+		},
+		{
+			name:     "required tags matched",
+			affinity: &Affinity{RequiredTags: map[string]string{"hw": "jetson"}},
+			want:     "",
+		},
+		{
+			name:     "required tags missed",
+			affinity: &Affinity{RequiredTags: map[string]string{"hw": "jetson", "region": "us"}},
+			want:     "miner does not have the required tags",
+		},
+		{
+			name:     "internal only",
+			affinity: &Affinity{InternalOnly: true},
+			want:     "miner is not internal",
+		},
+		{
+			name:     "internal only with an internal miner",
+			affinity: &Affinity{InternalOnly: true},
+			internal: true,
+			want:     "",
+		},
+		{
+			name:     "excluded miner",
+			affinity: &Affinity{ExcludeMiners: []string{"other", "miner"}},
+			want:     "miner is excluded",
+		},
+		{
+			name:     "excluded owner",
+			affinity: &Affinity{ExcludeUsers: []string{"owner"}},
+			want:     "miner owner is excluded",
+		},
+		{
+			name:       "anti-affinity with the same miner",
+			affinity:   &Affinity{},
+			colocation: &colocation{scope: AntiAffinityScopeMiner, miners: map[string]bool{"miner": true}},
+			want:       "anti-affinity with a task on the same miner",
+		},
+		{
+			name:       "anti-affinity with the same owner",
+			affinity:   &Affinity{},
+			colocation: &colocation{scope: AntiAffinityScopeUser, users: map[string]bool{"owner": true}},
+			want:       "anti-affinity with a task on the same user",
+		},
+		{
+			name:       "anti-affinity with another owner",
+			affinity:   &Affinity{},
+			colocation: &colocation{scope: AntiAffinityScopeUser, users: map[string]bool{"someone": true}},
+			want:       "",
+		},
+	}
+
+	e := newTestEngine(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := *miner
+			m.IsInternal = tt.internal
+			c := &Candidate{Miner: &m, colocation: tt.colocation}
+
+			if got := e.checkAffinity(&Request{Affinity: tt.affinity}, c); got != tt.want {
+				t.Errorf("checkAffinity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreference(t *testing.T) {
+	c := &Candidate{Miner: &datastore.Miner{Tags: datastore.Tags{"hw": "jetson", "region": "eu"}}}
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want float64
+	}{
+		{"none preferred", nil, 0},
+		{"all matched", map[string]string{"hw": "jetson", "region": "eu"}, 1},
+		{"half matched", map[string]string{"hw": "jetson", "region": "us"}, 0.5},
+		{"none matched", map[string]string{"gpu": "yes"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Affinity: &Affinity{PreferredTags: tt.tags}}
+			if got := preference(req, c); got != tt.want {
+				t.Errorf("preference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
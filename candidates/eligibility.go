@@ -0,0 +1,63 @@
+package candidates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+// Eligibility is the policy external miners must satisfy to register and to
+// be picked as candidates. Stakes are in VID. Internal miners are not staked
+// and always pass.
+type Eligibility struct {
+	WorkerStates  []string `json:"worker_states" split_words:"true" default:"BONDED"`
+	MinSelfStake  float64  `json:"min_self_stake" split_words:"true"`
+	MinTotalStake float64  `json:"min_total_stake" split_words:"true"`
+}
+
+var DefaultEligibility = Eligibility{
+	WorkerStates: []string{"BONDED"},
+}
+
+// Check returns why the miner is not eligible, or an empty string.
+func (p *Eligibility) Check(miner *datastore.Miner) string {
+	if miner.IsInternal {
+		return ""
+	}
+
+	if len(p.WorkerStates) > 0 {
+		if miner.WorkerInfo == nil {
+			return "worker state is unknown"
+		}
+
+		state := miner.WorkerInfo.State.String()
+		allowed := false
+		for _, s := range p.WorkerStates {
+			if strings.EqualFold(s, state) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "worker is " + strings.ToLower(state)
+		}
+	}
+
+	if stake := miner.SelfStake(); stake < p.MinSelfStake {
+		return fmt.Sprintf("self stake %g VID is below %g VID", stake, p.MinSelfStake)
+	}
+	if stake := miner.TotalStake(); stake < p.MinTotalStake {
+		return fmt.Sprintf("total stake %g VID is below %g VID", stake, p.MinTotalStake)
+	}
+
+	return ""
+}
+
+func (e *Engine) Eligibility() Eligibility {
+	return e.eligibility
+}
+
+func (e *Engine) checkEligibility(req *Request, c *Candidate) string {
+	return e.eligibility.Check(c.Miner)
+}
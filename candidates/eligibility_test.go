@@ -0,0 +1,110 @@
+package candidates
+
+import (
+	"testing"
+
+	emitterv1 "github.com/videocoin/cloud-api/emitter/v1"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func TestEligibilityCheck(t *testing.T) {
+	bonded := &emitterv1.WorkerResponse{
+		State:      emitterv1.WorkerStateBonded,
+		SelfStake:  "10000000000000000000",
+		TotalStake: "50000000000000000000",
+	}
+
+	policy := &Eligibility{
+		WorkerStates:  []string{"bonded"},
+		MinSelfStake:  10,
+		MinTotalStake: 50,
+	}
+
+	tests := []struct {
+		name   string
+		policy *Eligibility
+		miner  *datastore.Miner
+		want   string
+	}{
+		{
+			name:   "internal miner",
+			policy: policy,
+			miner:  &datastore.Miner{IsInternal: true},
+			want:   "",
+		},
+		{
+			name:   "empty policy",
+			policy: &Eligibility{},
+			miner:  &datastore.Miner{},
+			want:   "",
+		},
+		{
+			name:   "default policy with a bonded worker",
+			policy: &DefaultEligibility,
+			miner:  &datastore.Miner{WorkerInfo: bonded},
+			want:   "",
+		},
+		{
+			name:   "default policy with an unbonded worker",
+			policy: &DefaultEligibility,
+			miner:  &datastore.Miner{WorkerInfo: &emitterv1.WorkerResponse{State: emitterv1.WorkerStateUnbonded}},
+			want:   "worker is unbonded",
+		},
+		{
+			name:   "all met",
+			policy: policy,
+			miner:  &datastore.Miner{WorkerInfo: bonded},
+			want:   "",
+		},
+		{
+			name:   "unknown worker",
+			policy: policy,
+			miner:  &datastore.Miner{},
+			want:   "worker state is unknown",
+		},
+		{
+			name:   "wrong worker state",
+			policy: policy,
+			miner: &datastore.Miner{WorkerInfo: &emitterv1.WorkerResponse{
+				State:      emitterv1.WorkerStateUnbonding,
+				SelfStake:  bonded.SelfStake,
+				TotalStake: bonded.TotalStake,
+			}},
+			want: "worker is unbonding",
+		},
+		{
+			name:   "self stake below the minimum",
+			policy: policy,
+			miner: &datastore.Miner{WorkerInfo: &emitterv1.WorkerResponse{
+				State:      emitterv1.WorkerStateBonded,
+				SelfStake:  "5000000000000000000",
+				TotalStake: bonded.TotalStake,
+			}},
+			want: "self stake 5 VID is below 10 VID",
+		},
+		{
+			name:   "total stake below the minimum",
+			policy: policy,
+			miner: &datastore.Miner{WorkerInfo: &emitterv1.WorkerResponse{
+				State:      emitterv1.WorkerStateBonded,
+				SelfStake:  bonded.SelfStake,
+				TotalStake: "20000000000000000000",
+			}},
+			want: "total stake 20 VID is below 50 VID",
+		},
+		{
+			name:   "stakes without a worker state",
+			policy: &Eligibility{MinSelfStake: 1},
+			miner:  &datastore.Miner{},
+			want:   "self stake 0 VID is below 1 VID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Check(tt.miner); got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package candidates
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+	v1 "github.com/videocoin/cloud-api/miners/v1"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type Request struct {
+	EncodeCapacity float64 `json:"encode_capacity"`
+	CpuCapacity    float64 `json:"cpu_capacity"`
+	Limit          int     `json:"limit"`
+
+	// Origin, when set, restricts and ranks candidates by location.
+	// Distances are in kilometers.
+	Origin       *Origin `json:"origin"`
+	MaxDistance  float64 `json:"max_distance"`
+	PreferRadius float64 `json:"prefer_radius"`
+
+	Affinity     *Affinity     `json:"affinity"`
+	Requirements *Requirements `json:"requirements"`
+}
+
+type Candidate struct {
+	Miner         *datastore.Miner
+	InMaintenance bool
+	Distance      *float64
+	colocation    *colocation
+	Score         float64
+	Scores        map[string]float64
+}
+
+type Engine struct {
+	logger          *logrus.Entry
+	ds              *datastore.Datastore
+	weights         Weights
+	hwScores        map[string]float64
+	eligibility     Eligibility
+	limit           int
+	uptimeWindow    time.Duration
+	freshnessWindow time.Duration
+	filters         []filter
+}
+
+func NewEngine(opts ...Option) (*Engine, error) {
+	e := &Engine{
+		weights:         DefaultWeights,
+		hwScores:        DefaultHardwareScores,
+		eligibility:     DefaultEligibility,
+		uptimeWindow:    time.Hour * 24,
+		freshnessWindow: time.Second * 20,
+	}
+	for _, o := range opts {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+
+	e.filters = []filter{
+		{"status", e.checkStatus},
+		{"blocked", e.checkBlocked},
+		{"eligibility", e.checkEligibility},
+		{"maintenance", e.checkMaintenance},
+		{"schedule", e.checkSchedule},
+		{"quarantine", e.checkQuarantine},
+		{"stability", e.checkStability},
+		{"capacity", e.checkCapacity},
+		{"requirements", e.checkRequirements},
+		{"reservation", e.checkReservation},
+		{"location", e.checkLocation},
+		{"affinity", e.checkAffinity},
+	}
+
+	return e, nil
+}
+
+// Candidates returns the miners able to take the request ordered by their
+// weighted score, best first, truncated to the requested or configured limit.
+func (e *Engine) Candidates(ctx context.Context, req *Request) ([]*Candidate, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "candidates.Candidates")
+	defer span.Finish()
+
+	candidates, err := e.ranked(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = e.limit
+	}
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	for _, c := range candidates {
+		e.logger.WithField("miner_id", c.Miner.ID).Debugf("candidate score %.4f %v", c.Score, c.Scores)
+	}
+
+	return candidates, nil
+}
+
+// Reserve picks the best candidate for the request and reserves it for the
+// task with a lease of the given ttl. Candidates taken concurrently by another
+// caller are skipped. It returns nil when no candidate could be reserved.
+func (e *Engine) Reserve(ctx context.Context, req *Request, taskID string, ttl time.Duration) (*Candidate, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "candidates.Reserve")
+	defer span.Finish()
+
+	span.SetTag("task_id", taskID)
+
+	candidates, err := e.ranked(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		err := e.ds.Miners.Reserve(ctx, c.Miner, taskID, ttl)
+		if err == datastore.ErrMinerNotAvailable {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	return nil, nil
+}
+
+// ranked returns every miner passing the filters, best first.
+func (e *Engine) ranked(ctx context.Context, req *Request) ([]*Candidate, error) {
+	miners, err := e.ds.Miners.ListCandidates(ctx, req.EncodeCapacity, req.CpuCapacity)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := e.prepare(ctx, req, miners)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*Candidate{}
+	for _, c := range all {
+		if e.evaluate(req, c) != "" {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	e.score(req, candidates)
+	rank(candidates)
+
+	return candidates, nil
+}
+
+// prepare wraps the miners into candidates along with the state the filters
+// need beyond the miner row.
+func (e *Engine) prepare(ctx context.Context, req *Request, miners []*datastore.Miner) ([]*Candidate, error) {
+	inMaintenance, err := e.ds.Miners.ListInMaintenance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	colocation, err := e.colocation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*Candidate{}
+	for _, miner := range miners {
+		candidates = append(candidates, &Candidate{
+			Miner:         miner,
+			InMaintenance: inMaintenance[miner.ID],
+			colocation:    colocation,
+		})
+	}
+
+	return candidates, nil
+}
+
+// rank sorts the candidates by score, best first.
+func rank(candidates []*Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score == candidates[j].Score {
+			return candidates[i].Miner.ID < candidates[j].Miner.ID
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+}
+
+func (e *Engine) colocation(ctx context.Context, req *Request) (*colocation, error) {
+	if req.Affinity == nil || req.Affinity.AntiAffinity == nil || len(req.Affinity.AntiAffinity.Tasks) == 0 {
+		return nil, nil
+	}
+
+	miners, err := e.ds.Miners.ListByTasks(ctx, req.Affinity.AntiAffinity.Tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &colocation{
+		scope:  req.Affinity.AntiAffinity.Scope,
+		miners: map[string]bool{},
+		users:  map[string]bool{},
+	}
+	if c.scope != AntiAffinityScopeMiner {
+		c.scope = AntiAffinityScopeUser
+	}
+	for _, miner := range miners {
+		c.miners[miner.ID] = true
+		c.users[miner.UserID] = true
+	}
+
+	return c, nil
+}
+
+type filter struct {
+	name  string
+	check func(req *Request, c *Candidate) string
+}
+
+// evaluate runs every filter and returns the reason of the first rejection,
+// or an empty string when the candidate passes them all.
+func (e *Engine) evaluate(req *Request, c *Candidate) string {
+	for _, f := range e.filters {
+		if reason := f.check(req, c); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// reasons runs every filter and returns all the rejection reasons.
+func (e *Engine) reasons(req *Request, c *Candidate) []string {
+	reasons := []string{}
+	for _, f := range e.filters {
+		if reason := f.check(req, c); reason != "" {
+			reasons = append(reasons, f.name+": "+reason)
+		}
+	}
+	return reasons
+}
+
+func (e *Engine) checkStatus(req *Request, c *Candidate) string {
+	if c.Miner.Status != v1.MinerStatusIdle {
+		return "miner is " + c.Miner.Status.String()
+	}
+	return ""
+}
+
+func (e *Engine) checkBlocked(req *Request, c *Candidate) string {
+	if c.Miner.IsBlock {
+		return "miner is blocked"
+	}
+	return ""
+}
+
+func (e *Engine) checkMaintenance(req *Request, c *Candidate) string {
+	if c.Miner.IsDraining {
+		return "miner is draining"
+	}
+	if c.InMaintenance {
+		return "miner is in a maintenance window"
+	}
+	return ""
+}
+
+func (e *Engine) checkQuarantine(req *Request, c *Candidate) string {
+	if c.Miner.IsQuarantined() {
+		return "miner is quarantined until " + c.Miner.QuarantinedUntil.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+func (e *Engine) checkStability(req *Request, c *Candidate) string {
+	if c.Miner.IsUnstable {
+		return "miner is unstable"
+	}
+	return ""
+}
+
+func (e *Engine) checkSchedule(req *Request, c *Candidate) string {
+	if c.Miner.IsScheduledOff() {
+		return "miner is scheduled off"
+	}
+	return ""
+}
+
+func (e *Engine) checkReservation(req *Request, c *Candidate) string {
+	if c.Miner.IsReserved() {
+		return "miner is reserved for task " + c.Miner.ReservedTaskID.String
+	}
+	return ""
+}
+
+func (e *Engine) checkCapacity(req *Request, c *Candidate) string {
+	if c.Miner.FreeSlots() == 0 {
+		return "no free task slots"
+	}
+	if c.Miner.SlotCapacity("encode") < req.EncodeCapacity {
+		return "not enough encode capacity"
+	}
+	if c.Miner.SlotCapacity("cpu") < req.CpuCapacity {
+		return "not enough cpu capacity"
+	}
+	return ""
+}
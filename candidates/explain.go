@@ -0,0 +1,87 @@
+package candidates
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Explanation is the outcome of every filter and scoring rule for a miner.
+type Explanation struct {
+	MinerID  string             `json:"miner_id"`
+	Name     string             `json:"name"`
+	Status   string             `json:"status"`
+	Eligible bool               `json:"eligible"`
+	Rank     int                `json:"rank,omitempty"`
+	Reasons  []string           `json:"reasons,omitempty"`
+	Score    float64            `json:"score"`
+	Scores   map[string]float64 `json:"scores"`
+}
+
+// Explain evaluates the request against all miners without side effects and
+// returns the outcome for all of them, or the given one. Unlike Candidates it
+// does not stop at the first failing filter. Eligible miners are scored and
+// ranked among all eligible miners as Candidates would, ignoring the limit;
+// rejected miners are scored among themselves.
+func (e *Engine) Explain(ctx context.Context, req *Request, minerID string) ([]*Explanation, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "candidates.Explain")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+
+	if minerID != "" {
+		if _, err := e.ds.Miners.Get(ctx, minerID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	miners, err := e.ds.Miners.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := e.prepare(ctx, req, miners)
+	if err != nil {
+		return nil, err
+	}
+
+	eligible := []*Candidate{}
+	rejected := []*Candidate{}
+	reasons := map[*Candidate][]string{}
+	for _, c := range all {
+		reasons[c] = e.reasons(req, c)
+		if len(reasons[c]) == 0 {
+			eligible = append(eligible, c)
+		} else {
+			rejected = append(rejected, c)
+		}
+	}
+
+	e.score(req, eligible)
+	rank(eligible)
+	e.score(req, rejected)
+	rank(rejected)
+
+	explanations := []*Explanation{}
+	for i, c := range append(eligible, rejected...) {
+		if minerID != "" && c.Miner.ID != minerID {
+			continue
+		}
+
+		explanation := &Explanation{
+			MinerID:  c.Miner.ID,
+			Name:     c.Miner.Name,
+			Status:   c.Miner.Status.String(),
+			Eligible: len(reasons[c]) == 0,
+			Reasons:  reasons[c],
+			Score:    c.Score,
+			Scores:   c.Scores,
+		}
+		if explanation.Eligible {
+			explanation.Rank = i + 1
+		}
+		explanations = append(explanations, explanation)
+	}
+
+	return explanations, nil
+}
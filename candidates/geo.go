@@ -0,0 +1,80 @@
+package candidates
+
+import (
+	"math"
+	"strings"
+
+	"github.com/videocoin/cloud-miners/ext"
+)
+
+const earthRadiusKm = 6371.0
+
+type Origin = ext.Origin
+
+// distanceKm returns the great-circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func (e *Engine) checkLocation(req *Request, c *Candidate) string {
+	if req.Origin == nil {
+		return ""
+	}
+
+	latitude, longitude, country, continent, ok := c.Miner.Geo()
+
+	if req.Origin.Country != "" && !strings.EqualFold(req.Origin.Country, country) {
+		return "miner is outside of country " + req.Origin.Country
+	}
+
+	if req.Origin.Continent != "" && !strings.EqualFold(req.Origin.Continent, continent) {
+		return "miner is outside of region " + req.Origin.Continent
+	}
+
+	originLat, originLon, hasCoords := req.Origin.Coords()
+	if !hasCoords {
+		return ""
+	}
+
+	if !ok {
+		if req.MaxDistance > 0 {
+			return "miner location is unknown"
+		}
+		return ""
+	}
+
+	distance := distanceKm(originLat, originLon, latitude, longitude)
+	c.Distance = &distance
+
+	if req.MaxDistance > 0 && distance > req.MaxDistance {
+		return "miner is too far from origin"
+	}
+
+	return ""
+}
+
+// proximity scores 1 inside the preferred radius and decays with the
+// distance beyond it. Without a radius it decays linearly up to the
+// antipode. Miners with unknown location score 0.
+func proximity(req *Request, c *Candidate) float64 {
+	if c.Distance == nil {
+		return 0
+	}
+
+	d := *c.Distance
+	if req.PreferRadius > 0 {
+		if d <= req.PreferRadius {
+			return 1
+		}
+		return req.PreferRadius / d
+	}
+
+	return clamp(1 - d/(math.Pi*earthRadiusKm))
+}
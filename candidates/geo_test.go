@@ -0,0 +1,137 @@
+package candidates
+
+import (
+	"math"
+	"testing"
+
+	"github.com/AlekSi/pointer"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 52.52, 13.40, 52.52, 13.40, 0},
+		{"berlin to paris", 52.52, 13.405, 48.8566, 2.3522, 878},
+		{"new york to london", 40.7128, -74.0060, 51.5074, -0.1278, 5570},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111},
+		{"antipodes", 0, 0, 0, 180, math.Pi * earthRadiusKm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distanceKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+			if math.Abs(got-tt.want) > 5 {
+				t.Errorf("distanceKm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLocation(t *testing.T) {
+	berlin := datastore.Info{"geo": map[string]interface{}{
+		"latitude":  52.52,
+		"longitude": 13.405,
+		"country":   "DE",
+		"continent": "EU",
+	}}
+
+	tests := []struct {
+		name       string
+		systemInfo datastore.Info
+		req        *Request
+		want       string
+		distance   bool
+	}{
+		{
+			name:       "no origin",
+			systemInfo: berlin,
+			req:        &Request{},
+		},
+		{
+			name:       "same country",
+			systemInfo: berlin,
+			req:        &Request{Origin: &Origin{Country: "de"}},
+		},
+		{
+			name:       "other country",
+			systemInfo: berlin,
+			req:        &Request{Origin: &Origin{Country: "FR"}},
+			want:       "miner is outside of country FR",
+		},
+		{
+			name:       "other continent",
+			systemInfo: berlin,
+			req:        &Request{Origin: &Origin{Continent: "NA"}},
+			want:       "miner is outside of region NA",
+		},
+		{
+			name:       "within the maximum distance",
+			systemInfo: berlin,
+			req:        &Request{Origin: &Origin{Latitude: pointer.ToFloat64(48.8566), Longitude: pointer.ToFloat64(2.3522)}, MaxDistance: 1000},
+			distance:   true,
+		},
+		{
+			name:       "beyond the maximum distance",
+			systemInfo: berlin,
+			req:        &Request{Origin: &Origin{Latitude: pointer.ToFloat64(48.8566), Longitude: pointer.ToFloat64(2.3522)}, MaxDistance: 500},
+			want:       "miner is too far from origin",
+			distance:   true,
+		},
+		{
+			name:       "unknown location with a maximum distance",
+			systemInfo: datastore.Info{},
+			req:        &Request{Origin: &Origin{Latitude: pointer.ToFloat64(48.8566), Longitude: pointer.ToFloat64(2.3522)}, MaxDistance: 500},
+			want:       "miner location is unknown",
+		},
+		{
+			name:       "unknown location without a maximum distance",
+			systemInfo: datastore.Info{},
+			req:        &Request{Origin: &Origin{Latitude: pointer.ToFloat64(48.8566), Longitude: pointer.ToFloat64(2.3522)}},
+		},
+	}
+
+	e := newTestEngine(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Candidate{Miner: &datastore.Miner{SystemInfo: tt.systemInfo}}
+
+			if got := e.checkLocation(tt.req, c); got != tt.want {
+				t.Errorf("checkLocation() = %q, want %q", got, tt.want)
+			}
+			if got := c.Distance != nil; got != tt.distance {
+				t.Errorf("distance set = %v, want %v", got, tt.distance)
+			}
+		})
+	}
+}
+
+func TestProximity(t *testing.T) {
+	tests := []struct {
+		name     string
+		distance *float64
+		radius   float64
+		want     float64
+	}{
+		{"unknown location", nil, 100, 0},
+		{"inside the radius", pointer.ToFloat64(50), 100, 1},
+		{"on the radius", pointer.ToFloat64(100), 100, 1},
+		{"twice the radius", pointer.ToFloat64(200), 100, 0.5},
+		{"no radius, same place", pointer.ToFloat64(0), 0, 1},
+		{"no radius, half way to the antipode", pointer.ToFloat64(math.Pi * earthRadiusKm / 2), 0, 0.5},
+		{"no radius, antipode", pointer.ToFloat64(math.Pi * earthRadiusKm), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proximity(&Request{PreferRadius: tt.radius}, &Candidate{Distance: tt.distance})
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("proximity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
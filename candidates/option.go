@@ -0,0 +1,61 @@
+package candidates
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type Option func(*Engine) error
+
+func WithLogger(logger *logrus.Entry) Option {
+	return func(e *Engine) error {
+		e.logger = logger
+		return nil
+	}
+}
+
+func WithDatastore(ds *datastore.Datastore) Option {
+	return func(e *Engine) error {
+		e.ds = ds
+		return nil
+	}
+}
+
+func WithWeights(weights Weights) Option {
+	return func(e *Engine) error {
+		e.weights = weights
+		return nil
+	}
+}
+
+func WithHardwareScores(scores map[string]float64) Option {
+	return func(e *Engine) error {
+		if len(scores) > 0 {
+			e.hwScores = scores
+		}
+		return nil
+	}
+}
+
+func WithEligibility(eligibility Eligibility) Option {
+	return func(e *Engine) error {
+		e.eligibility = eligibility
+		return nil
+	}
+}
+
+func WithLimit(limit int) Option {
+	return func(e *Engine) error {
+		e.limit = limit
+		return nil
+	}
+}
+
+func WithUptimeWindow(d time.Duration) Option {
+	return func(e *Engine) error {
+		e.uptimeWindow = d
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package candidates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/videocoin/cloud-miners/ext"
+)
+
+type Requirements = ext.Requirements
+
+func (e *Engine) checkRequirements(req *Request, c *Candidate) string {
+	r := req.Requirements
+	if r == nil {
+		return ""
+	}
+
+	capabilities := c.Miner.Capabilities()
+
+	if r.Arch != "" && !strings.EqualFold(capabilities.Arch, r.Arch) {
+		return "architecture is not " + r.Arch
+	}
+	if capabilities.MemoryMB < r.MinMemoryMB {
+		return fmt.Sprintf("memory %gMB is below %gMB", capabilities.MemoryMB, r.MinMemoryMB)
+	}
+	if r.HWEncoder != "" && !capabilities.HasHWEncoder(r.HWEncoder) {
+		return "no " + r.HWEncoder + " encoder"
+	}
+
+	if r.Codec == "" {
+		return ""
+	}
+
+	fps := capabilities.Throughput(r.Codec, r.Profile, r.Width, r.Height, r.HWEncoder)
+	if fps == 0 {
+		return fmt.Sprintf("cannot encode %s %s %dx%d", r.Codec, r.Profile, r.Width, r.Height)
+	}
+	if fps/float64(c.Miner.Slots()) < r.FPS {
+		return fmt.Sprintf("%s throughput %gfps per slot is below %gfps", r.Codec, fps/float64(c.Miner.Slots()), r.FPS)
+	}
+
+	return ""
+}
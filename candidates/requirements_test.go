@@ -0,0 +1,128 @@
+package candidates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func capacityInfo() datastore.Info {
+	return datastore.Info{
+		"encode":      100.0,
+		"cpu":         100.0,
+		"arch":        "arm64",
+		"memory_mb":   4096.0,
+		"hw_encoders": []interface{}{"nvenc"},
+		"codecs": []interface{}{
+			map[string]interface{}{"codec": "h264", "profile": "main", "max_width": 1920.0, "max_height": 1080.0, "fps": 60.0},
+			map[string]interface{}{"codec": "h264", "profile": "main", "max_width": 1920.0, "max_height": 1080.0, "fps": 240.0, "hw_encoder": "nvenc"},
+			map[string]interface{}{"codec": "h265", "profile": "main", "max_width": 1280.0, "max_height": 720.0, "fps": 30.0},
+		},
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	capabilities := (&datastore.Miner{CapacityInfo: capacityInfo()}).Capabilities()
+
+	tests := []struct {
+		name      string
+		codec     string
+		profile   string
+		width     int
+		height    int
+		hwEncoder string
+		want      float64
+	}{
+		{"best of software and hardware", "h264", "", 1920, 1080, "", 240},
+		{"any encoder matched by profile", "H264", "main", 1280, 720, "", 240},
+		{"hardware encoder required", "h264", "main", 1920, 1080, "nvenc", 240},
+		{"unknown hardware encoder", "h264", "main", 1920, 1080, "qsv", 0},
+		{"resolution above the maximum", "h265", "main", 1920, 1080, "", 0},
+		{"resolution within the maximum", "h265", "", 1280, 720, "", 30},
+		{"unknown profile", "h264", "high", 1280, 720, "", 0},
+		{"unknown codec", "vp9", "", 640, 360, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capabilities.Throughput(tt.codec, tt.profile, tt.width, tt.height, tt.hwEncoder)
+			if got != tt.want {
+				t.Errorf("Throughput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	tests := []struct {
+		name  string
+		slots int
+		info  datastore.Info
+		req   *Requirements
+		want  string
+	}{
+		{
+			name: "no requirements",
+			info: capacityInfo(),
+			req:  nil,
+			want: "",
+		},
+		{
+			name: "all met",
+			info: capacityInfo(),
+			req:  &Requirements{Codec: "h264", Width: 1920, Height: 1080, FPS: 30, Arch: "ARM64", MinMemoryMB: 2048, HWEncoder: "nvenc"},
+			want: "",
+		},
+		{
+			name: "architecture",
+			info: capacityInfo(),
+			req:  &Requirements{Arch: "amd64"},
+			want: "architecture is not amd64",
+		},
+		{
+			name: "memory",
+			info: capacityInfo(),
+			req:  &Requirements{MinMemoryMB: 8192},
+			want: "memory 4096MB is below 8192MB",
+		},
+		{
+			name: "hardware encoder",
+			info: capacityInfo(),
+			req:  &Requirements{HWEncoder: "qsv"},
+			want: "no qsv encoder",
+		},
+		{
+			name: "codec",
+			info: capacityInfo(),
+			req:  &Requirements{Codec: "vp9", Profile: "0", Width: 640, Height: 360},
+			want: "cannot encode vp9 0 640x360",
+		},
+		{
+			name:  "throughput is shared by the slots",
+			slots: 4,
+			info:  capacityInfo(),
+			req:   &Requirements{Codec: "h265", Width: 1280, Height: 720, FPS: 25},
+			want:  "h265 throughput 7.5fps per slot is below 25fps",
+		},
+		{
+			name: "agent without capabilities",
+			info: datastore.Info{"encode": 100.0, "cpu": 100.0},
+			req:  &Requirements{Codec: "h264"},
+			want: "cannot encode h264",
+		},
+	}
+
+	e := newTestEngine(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Candidate{Miner: &datastore.Miner{TaskSlots: tt.slots, CapacityInfo: tt.info}}
+
+			got := e.checkRequirements(&Request{Requirements: tt.req}, c)
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("checkRequirements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package candidates
+
+import (
+	"time"
+)
+
+const (
+	FactorStake       = "stake"
+	FactorCapacity    = "capacity"
+	FactorUptime      = "uptime"
+	FactorTaskSuccess = "task_success"
+	FactorHardware    = "hardware"
+	FactorFreshness   = "freshness"
+	FactorProximity   = "proximity"
+	FactorAffinity    = "affinity"
+	FactorVerified    = "verified"
+	FactorReputation  = "reputation"
+)
+
+type Weights struct {
+	Stake       float64 `default:"1"`
+	Capacity    float64 `default:"1"`
+	Uptime      float64 `default:"1"`
+	TaskSuccess float64 `split_words:"true" default:"1"`
+	Hardware    float64 `default:"0.5"`
+	Freshness   float64 `default:"0.5"`
+	Proximity   float64 `default:"1"`
+	Affinity    float64 `default:"1"`
+	Verified    float64 `default:"0.5"`
+	Reputation  float64 `default:"1"`
+}
+
+var DefaultWeights = Weights{
+	Stake:       1,
+	Capacity:    1,
+	Uptime:      1,
+	TaskSuccess: 1,
+	Hardware:    0.5,
+	Freshness:   0.5,
+	Proximity:   1,
+	Affinity:    1,
+	Verified:    0.5,
+	Reputation:  1,
+}
+
+// DefaultHardwareScores rates the hw tag reported on registration; miners
+// without the tag are rated by the "default" entry.
+var DefaultHardwareScores = map[string]float64{
+	"default":     1,
+	"jetson":      0.6,
+	"raspberrypi": 0.3,
+}
+
+type factor struct {
+	name   string
+	weight float64
+	score  func(c *Candidate) float64
+}
+
+// score computes every factor in the [0, 1] range and the weighted average
+// of them as the candidate score.
+func (e *Engine) score(req *Request, candidates []*Candidate) {
+	var maxStake, maxEncode, maxCPU float64
+	for _, c := range candidates {
+		maxStake = max(maxStake, c.Miner.TotalStake())
+		maxEncode = max(maxEncode, c.Miner.RemainingCapacity("encode")-req.EncodeCapacity)
+		maxCPU = max(maxCPU, c.Miner.RemainingCapacity("cpu")-req.CpuCapacity)
+	}
+
+	now := time.Now()
+	factors := []factor{
+		{FactorStake, e.weights.Stake, func(c *Candidate) float64 {
+			return ratio(c.Miner.TotalStake(), maxStake)
+		}},
+		{FactorCapacity, e.weights.Capacity, func(c *Candidate) float64 {
+			encode := ratio(c.Miner.RemainingCapacity("encode")-req.EncodeCapacity, maxEncode)
+			cpu := ratio(c.Miner.RemainingCapacity("cpu")-req.CpuCapacity, maxCPU)
+			return (encode + cpu) / 2
+		}},
+		{FactorUptime, e.weights.Uptime, func(c *Candidate) float64 {
+			if c.Miner.OnlineAt == nil {
+				return 0
+			}
+			return clamp(now.Sub(*c.Miner.OnlineAt).Seconds() / e.uptimeWindow.Seconds())
+		}},
+		{FactorTaskSuccess, e.weights.TaskSuccess, func(c *Candidate) float64 {
+			// Laplace smoothing keeps miners without history at 0.5.
+			assigned := float64(c.Miner.TasksAssigned)
+			lost := float64(c.Miner.TasksLost)
+			return clamp((assigned - lost + 1) / (assigned + 2))
+		}},
+		{FactorHardware, e.weights.Hardware, func(c *Candidate) float64 {
+			if s, ok := e.hwScores[c.Miner.Tags["hw"]]; ok {
+				return s
+			}
+			return e.hwScores["default"]
+		}},
+		{FactorVerified, e.weights.Verified, func(c *Candidate) float64 {
+			// Claims that diverge from the benchmark rank below unverified ones.
+			switch {
+			case c.Miner.CapacityDiverged:
+				return 0
+			case c.Miner.IsVerified():
+				return 1
+			}
+			return 0.5
+		}},
+		{FactorReputation, e.weights.Reputation, func(c *Candidate) float64 {
+			return c.Miner.Reputation.Score()
+		}},
+		{FactorFreshness, e.weights.Freshness, func(c *Candidate) float64 {
+			if c.Miner.LastPingAt == nil {
+				return 0
+			}
+			return clamp(1 - now.Sub(*c.Miner.LastPingAt).Seconds()/e.freshnessWindow.Seconds())
+		}},
+	}
+
+	if _, _, ok := req.Origin.Coords(); ok {
+		factors = append(factors, factor{FactorProximity, e.weights.Proximity, func(c *Candidate) float64 {
+			return proximity(req, c)
+		}})
+	}
+
+	if req.Affinity != nil && len(req.Affinity.PreferredTags) > 0 {
+		factors = append(factors, factor{FactorAffinity, e.weights.Affinity, func(c *Candidate) float64 {
+			return preference(req, c)
+		}})
+	}
+
+	var total float64
+	for _, f := range factors {
+		total += f.weight
+	}
+
+	for _, c := range candidates {
+		c.Scores = map[string]float64{}
+		c.Score = 0
+		for _, f := range factors {
+			s := f.score(c)
+			c.Scores[f.name] = s
+			c.Score += f.weight * s
+		}
+		if total > 0 {
+			c.Score /= total
+		}
+	}
+}
+
+func ratio(value, max float64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return clamp(value / max)
+}
+
+func clamp(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,162 @@
+package candidates
+
+import (
+	"math"
+	"testing"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func newTestEngine(t *testing.T, opts ...Option) *Engine {
+	e, err := NewEngine(opts...)
+	if err != nil {
+		t.Fatalf("failed to create engine: %s", err)
+	}
+	return e
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights Weights
+		req     *Request
+		miners  []*datastore.Miner
+		factor  string
+		want    []float64
+	}{
+		{
+			name:    "capacity relative to the best candidate",
+			weights: Weights{Capacity: 1},
+			req:     &Request{EncodeCapacity: 10, CpuCapacity: 10},
+			miners: []*datastore.Miner{
+				{ID: "a", CapacityInfo: datastore.Info{"encode": 110.0, "cpu": 110.0}},
+				{ID: "b", CapacityInfo: datastore.Info{"encode": 60.0, "cpu": 60.0}},
+				{ID: "c", CapacityInfo: datastore.Info{"encode": 10.0, "cpu": 10.0}},
+			},
+			factor: FactorCapacity,
+			want:   []float64{1, 0.5, 0},
+		},
+		{
+			name:    "busy slots do not count as remaining capacity",
+			weights: Weights{Capacity: 1},
+			req:     &Request{},
+			miners: []*datastore.Miner{
+				{ID: "a", TaskSlots: 2, CapacityInfo: datastore.Info{"encode": 100.0, "cpu": 100.0}},
+				{ID: "b", TaskSlots: 2, ActiveTasks: 1, CapacityInfo: datastore.Info{"encode": 100.0, "cpu": 100.0}},
+			},
+			factor: FactorCapacity,
+			want:   []float64{1, 0.5},
+		},
+		{
+			name:    "task success smoothed towards one half",
+			weights: Weights{TaskSuccess: 1},
+			req:     &Request{},
+			miners: []*datastore.Miner{
+				{ID: "new"},
+				{ID: "reliable", TasksAssigned: 8},
+				{ID: "lossy", TasksAssigned: 8, TasksLost: 8},
+			},
+			factor: FactorTaskSuccess,
+			want:   []float64{0.5, 0.9, 0.1},
+		},
+		{
+			name:    "hardware class from the hw tag",
+			weights: Weights{Hardware: 1},
+			req:     &Request{},
+			miners: []*datastore.Miner{
+				{ID: "a"},
+				{ID: "b", Tags: datastore.Tags{"hw": "jetson"}},
+				{ID: "c", Tags: datastore.Tags{"hw": "raspberrypi"}},
+			},
+			factor: FactorHardware,
+			want:   []float64{1, 0.6, 0.3},
+		},
+		{
+			name:    "diverged capacity ranks below unverified",
+			weights: Weights{Verified: 1},
+			req:     &Request{},
+			miners: []*datastore.Miner{
+				{ID: "unverified"},
+				{ID: "diverged", CapacityDiverged: true},
+			},
+			factor: FactorVerified,
+			want:   []float64{0.5, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, WithWeights(tt.weights))
+
+			candidates := []*Candidate{}
+			for _, miner := range tt.miners {
+				candidates = append(candidates, &Candidate{Miner: miner})
+			}
+
+			e.score(tt.req, candidates)
+
+			for i, c := range candidates {
+				got := c.Scores[tt.factor]
+				if math.Abs(got-tt.want[i]) > 1e-9 {
+					t.Errorf("%s: %s = %v, want %v", c.Miner.ID, tt.factor, got, tt.want[i])
+				}
+				// The factor is the only weighted one, so it is the score.
+				if math.Abs(c.Score-got) > 1e-9 {
+					t.Errorf("%s: score = %v, want %v", c.Miner.ID, c.Score, got)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreOptionalFactors(t *testing.T) {
+	lat, lon := 52.52, 13.40
+
+	tests := []struct {
+		name string
+		req  *Request
+		want map[string]bool
+	}{
+		{
+			name: "no origin nor preference",
+			req:  &Request{},
+			want: map[string]bool{FactorProximity: false, FactorAffinity: false},
+		},
+		{
+			name: "origin without coordinates",
+			req:  &Request{Origin: &Origin{Country: "DE"}},
+			want: map[string]bool{FactorProximity: false, FactorAffinity: false},
+		},
+		{
+			name: "origin with coordinates",
+			req:  &Request{Origin: &Origin{Latitude: &lat, Longitude: &lon}},
+			want: map[string]bool{FactorProximity: true, FactorAffinity: false},
+		},
+		{
+			name: "preferred tags",
+			req:  &Request{Affinity: &Affinity{PreferredTags: map[string]string{"hw": "jetson"}}},
+			want: map[string]bool{FactorProximity: false, FactorAffinity: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t)
+
+			candidates := []*Candidate{{Miner: &datastore.Miner{ID: "a"}}}
+			e.score(tt.req, candidates)
+
+			for name, want := range tt.want {
+				if _, got := candidates[0].Scores[name]; got != want {
+					t.Errorf("factor %s present = %v, want %v", name, got, want)
+				}
+			}
+
+			for name, s := range candidates[0].Scores {
+				if s < 0 || s > 1 {
+					t.Errorf("factor %s = %v, out of [0, 1]", name, s)
+				}
+			}
+		})
+	}
+}
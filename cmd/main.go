@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -77,7 +78,10 @@ func main() {
 	}
 
 	log.Info("stopping")
-	err = svc.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = svc.Stop(ctx)
 	if err != nil {
 		log.Error(err)
 		return
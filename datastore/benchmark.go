@@ -0,0 +1,20 @@
+package datastore
+
+// BenchmarkFrames is the length of the reference workload sent with benchmark
+// commands. Verified encode capacity is expressed in frames per second of it.
+const BenchmarkFrames = 900
+
+func NewBenchmarkPayload() Info {
+	return Info{
+		"workload": "h264_1080p30",
+		"frames":   BenchmarkFrames,
+	}
+}
+
+// BenchmarkResult is reported by the agent under the benchmark key of the
+// capacity info once it ran a benchmark command.
+type BenchmarkResult struct {
+	CommandID string  `json:"command_id"`
+	Frames    float64 `json:"frames"`
+	ElapsedMs float64 `json:"elapsed_ms"`
+}
@@ -0,0 +1,28 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+// Block records a period during which a miner is excluded from scheduling
+// and cannot register.
+type Block struct {
+	ID          string         `json:"id"`
+	MinerID     string         `json:"miner_id"`
+	Reason      dbr.NullString `json:"reason"`
+	BlockedBy   dbr.NullString `json:"blocked_by"`
+	BlockedAt   *time.Time     `json:"blocked_at"`
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	UnblockedBy dbr.NullString `json:"unblocked_by"`
+	UnblockedAt *time.Time     `json:"unblocked_at"`
+}
+
+func (Block) TableName() string {
+	return "miner_blocks"
+}
+
+func (b *Block) IsActive() bool {
+	return b.UnblockedAt == nil && (b.ExpiresAt == nil || b.ExpiresAt.After(time.Now()))
+}
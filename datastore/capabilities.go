@@ -0,0 +1,78 @@
+package datastore
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Capabilities is the hardware and codec description reported by the agent
+// next to the encode and cpu scalars of capacity_info.
+type Capabilities struct {
+	Arch       string           `json:"arch"`
+	MemoryMB   float64          `json:"memory_mb"`
+	HWEncoders []string         `json:"hw_encoders"`
+	Codecs     []*CodecCapacity `json:"codecs"`
+}
+
+// CodecCapacity is the measured throughput of a codec and profile up to the
+// given resolution, encoded in software or with HWEncoder.
+type CodecCapacity struct {
+	Codec     string  `json:"codec"`
+	Profile   string  `json:"profile"`
+	MaxWidth  int     `json:"max_width"`
+	MaxHeight int     `json:"max_height"`
+	FPS       float64 `json:"fps"`
+	HWEncoder string  `json:"hw_encoder"`
+}
+
+// Capabilities decodes the extended fields of capacity_info. Agents that only
+// report the scalars have empty capabilities.
+func (m *Miner) Capabilities() *Capabilities {
+	capabilities := &Capabilities{}
+	if len(m.CapacityInfo) == 0 {
+		return capabilities
+	}
+
+	b, err := json.Marshal(m.CapacityInfo)
+	if err != nil {
+		return capabilities
+	}
+
+	_ = json.Unmarshal(b, capabilities)
+
+	return capabilities
+}
+
+func (c *Capabilities) HasHWEncoder(name string) bool {
+	for _, encoder := range c.HWEncoders {
+		if strings.EqualFold(encoder, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Throughput returns the best frame rate the miner reported for the codec,
+// profile and resolution, or zero when it cannot encode them. An empty
+// profile or hwEncoder matches any.
+func (c *Capabilities) Throughput(codec, profile string, width, height int, hwEncoder string) float64 {
+	best := 0.0
+	for _, cc := range c.Codecs {
+		if !strings.EqualFold(cc.Codec, codec) {
+			continue
+		}
+		if profile != "" && !strings.EqualFold(cc.Profile, profile) {
+			continue
+		}
+		if hwEncoder != "" && !strings.EqualFold(cc.HWEncoder, hwEncoder) {
+			continue
+		}
+		if cc.MaxWidth < width || cc.MaxHeight < height {
+			continue
+		}
+		if cc.FPS > best {
+			best = cc.FPS
+		}
+	}
+	return best
+}
@@ -0,0 +1,68 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+type CommandType string
+
+const (
+	CommandTypeRestart           CommandType = "restart"
+	CommandTypeUpgrade           CommandType = "upgrade"
+	CommandTypeUploadDiagnostics CommandType = "upload_diagnostics"
+	CommandTypeBenchmark         CommandType = "benchmark"
+)
+
+// DefaultCommandTTL is how long a command waits for delivery and results
+// unless told otherwise.
+const DefaultCommandTTL = time.Hour
+
+func ParseCommandType(s string) (CommandType, error) {
+	switch t := CommandType(s); t {
+	case CommandTypeRestart, CommandTypeUpgrade, CommandTypeUploadDiagnostics, CommandTypeBenchmark:
+		return t, nil
+	}
+
+	return "", fmt.Errorf("unknown command type %q", s)
+}
+
+type CommandStatus string
+
+const (
+	CommandStatusPending   CommandStatus = "pending"
+	CommandStatusDelivered CommandStatus = "delivered"
+	CommandStatusSucceeded CommandStatus = "succeeded"
+	CommandStatusFailed    CommandStatus = "failed"
+	CommandStatusExpired   CommandStatus = "expired"
+)
+
+type Command struct {
+	ID          string         `json:"id"`
+	MinerID     string         `json:"miner_id"`
+	UserID      dbr.NullString `json:"user_id"`
+	Type        CommandType    `json:"type"`
+	Payload     Info           `json:"payload" sql:"type:json"`
+	Status      CommandStatus  `json:"status"`
+	Result      Info           `json:"result" sql:"type:json"`
+	CreatedAt   *time.Time     `json:"created_at"`
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	DeliveredAt *time.Time     `json:"delivered_at"`
+	CompletedAt *time.Time     `json:"completed_at"`
+}
+
+func (Command) TableName() string {
+	return "miner_commands"
+}
+
+func (c *Command) IsExpired() bool {
+	return c.ExpiresAt != nil && c.ExpiresAt.Before(time.Now())
+}
+
+func (c *Command) IsCompleted() bool {
+	return c.Status == CommandStatusSucceeded ||
+		c.Status == CommandStatusFailed ||
+		c.Status == CommandStatusExpired
+}
@@ -6,7 +6,15 @@ import (
 )
 
 type Datastore struct {
-	Miners *MinerDatastore
+	Miners    *MinerDatastore
+	Commands  *CommandDatastore
+	Rollouts  *RolloutDatastore
+	Profiles  *ConfigProfileDatastore
+	Pins      *PinDatastore
+	Incidents *IncidentDatastore
+	Leader    *LeaderDatastore
+
+	db *gorm.DB
 }
 
 func NewDatastore(uri string) (*Datastore, error) {
@@ -19,6 +27,8 @@ func NewDatastore(uri string) (*Datastore, error) {
 
 	db.LogMode(true)
 
+	ds.db = db
+
 	minersDs, err := NewMinerDatastore(db)
 	if err != nil {
 		return nil, err
@@ -26,6 +36,48 @@ func NewDatastore(uri string) (*Datastore, error) {
 
 	ds.Miners = minersDs
 
+	commandsDs, err := NewCommandDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Commands = commandsDs
+
+	rolloutsDs, err := NewRolloutDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Rollouts = rolloutsDs
+
+	profilesDs, err := NewConfigProfileDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Profiles = profilesDs
+
+	pinsDs, err := NewPinDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Pins = pinsDs
+
+	incidentsDs, err := NewIncidentDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Incidents = incidentsDs
+
+	leaderDs, err := NewLeaderDatastore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Leader = leaderDs
+
 	return ds, nil
 }
 
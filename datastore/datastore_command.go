@@ -0,0 +1,290 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/mailru/dbr"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command is not found")
+)
+
+type CommandDatastore struct {
+	db *gorm.DB
+}
+
+func NewCommandDatastore(db *gorm.DB) (*CommandDatastore, error) {
+	return &CommandDatastore{db: db}, nil
+}
+
+func (ds *CommandDatastore) Enqueue(ctx context.Context, minerID, userID string, commandType CommandType, payload Info, ttl time.Duration) (*Command, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Enqueue")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("type", commandType)
+
+	now := time.Now()
+	command := &Command{
+		ID:        uuid.New().String(),
+		MinerID:   minerID,
+		UserID:    dbr.NewNullString(userID),
+		Type:      commandType,
+		Payload:   payload,
+		Status:    CommandStatusPending,
+		CreatedAt: pointer.ToTime(now),
+	}
+
+	if command.Payload == nil {
+		command.Payload = Info{}
+	}
+
+	if ttl > 0 {
+		command.ExpiresAt = pointer.ToTime(now.Add(ttl))
+	}
+
+	err := ds.db.Create(command).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create command: %s", err)
+	}
+
+	return command, nil
+}
+
+func (ds *CommandDatastore) Get(ctx context.Context, id, minerID string) (*Command, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Get")
+	defer span.Finish()
+
+	span.SetTag("id", id)
+	span.SetTag("miner_id", minerID)
+
+	command := new(Command)
+
+	qs := ds.db.Where("id = ?", id)
+	if minerID != "" {
+		qs = qs.Where("miner_id = ?", minerID)
+	}
+
+	if err := qs.First(command).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCommandNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get command by id: %s", err)
+	}
+
+	return command, nil
+}
+
+func (ds *CommandDatastore) List(ctx context.Context, minerID string, fltr *ListFilter) ([]*Command, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "List")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+
+	commands := []*Command{}
+
+	qs := ds.db.Where("miner_id = ?", minerID).Order("created_at DESC")
+	if fltr != nil {
+		if fltr.Limit != nil {
+			qs = qs.Limit(*fltr.Limit)
+		}
+		if fltr.Offset != nil {
+			qs = qs.Offset(*fltr.Offset)
+		}
+	}
+
+	if err := qs.Find(&commands).Error; err != nil {
+		return nil, fmt.Errorf("failed to list commands: %s", err)
+	}
+
+	return commands, nil
+}
+
+// HasOpen reports whether the miner has a pending or delivered command of the
+// given type.
+func (ds *CommandDatastore) HasOpen(ctx context.Context, minerID string, commandType CommandType) (bool, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "HasOpen")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("type", commandType)
+
+	count := 0
+	err := ds.db.
+		Model(&Command{}).
+		Where("miner_id = ? AND type = ? AND status IN (?)", minerID, commandType, []CommandStatus{CommandStatusPending, CommandStatusDelivered}).
+		Count(&count).
+		Error
+	if err != nil {
+		return false, fmt.Errorf("failed to count open commands: %s", err)
+	}
+
+	return count > 0, nil
+}
+
+// CountFailedSince counts the commands that failed since the given time,
+// including the delivered ones that expired without a result.
+func (ds *CommandDatastore) CountFailedSince(ctx context.Context, minerID string, commandType CommandType, since time.Time) (int, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CountFailedSince")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("type", commandType)
+
+	count := 0
+	err := ds.db.
+		Model(&Command{}).
+		Where("miner_id = ? AND type = ? AND completed_at > ?", minerID, commandType, since).
+		Where("status = ? OR (status = ? AND delivered_at IS NOT NULL)", CommandStatusFailed, CommandStatusExpired).
+		Count(&count).
+		Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed commands: %s", err)
+	}
+
+	return count, nil
+}
+
+func (ds *CommandDatastore) CountExpiredSince(ctx context.Context, minerID string, commandType CommandType, since time.Time) (int, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CountExpiredSince")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("type", commandType)
+
+	count := 0
+	err := ds.db.
+		Model(&Command{}).
+		Where("miner_id = ? AND type = ? AND status = ? AND completed_at > ?", minerID, commandType, CommandStatusExpired, since).
+		Count(&count).
+		Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count expired commands: %s", err)
+	}
+
+	return count, nil
+}
+
+// Deliver returns the pending commands of the miner in the order they were
+// enqueued and marks them as delivered.
+func (ds *CommandDatastore) Deliver(ctx context.Context, minerID string) ([]*Command, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Deliver")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+
+	tx := ds.db.Begin()
+
+	commands := []*Command{}
+	err := tx.
+		Set("gorm:query_option", "FOR UPDATE").
+		Where("miner_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", minerID, CommandStatusPending, time.Now()).
+		Order("created_at").
+		Find(&commands).
+		Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to list pending commands: %s", err)
+	}
+
+	if len(commands) == 0 {
+		tx.Rollback()
+		return commands, nil
+	}
+
+	ids := []string{}
+	for _, command := range commands {
+		ids = append(ids, command.ID)
+	}
+
+	now := time.Now()
+	err = tx.
+		Model(&Command{}).
+		Where("id IN (?)", ids).
+		Updates(map[string]interface{}{
+			"status":       CommandStatusDelivered,
+			"delivered_at": now,
+		}).
+		Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to mark commands as delivered: %s", err)
+	}
+
+	tx.Commit()
+
+	for _, command := range commands {
+		command.Status = CommandStatusDelivered
+		command.DeliveredAt = pointer.ToTime(now)
+	}
+
+	return commands, nil
+}
+
+// Ack stores the result reported by the agent for a delivered command.
+func (ds *CommandDatastore) Ack(ctx context.Context, command *Command, succeeded bool, result Info) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Ack")
+	defer span.Finish()
+
+	span.SetTag("id", command.ID)
+	span.SetTag("succeeded", succeeded)
+
+	status := CommandStatusFailed
+	if succeeded {
+		status = CommandStatusSucceeded
+	}
+
+	if result == nil {
+		result = Info{}
+	}
+
+	now := time.Now()
+	err := ds.db.
+		Model(command).
+		Where("status = ?", CommandStatusDelivered).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"result":       result,
+			"completed_at": now,
+		}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to ack command: %s", err)
+	}
+
+	command.Status = status
+	command.Result = result
+	command.CompletedAt = pointer.ToTime(now)
+
+	return nil
+}
+
+// ExpirePending marks pending and delivered commands whose expiry has passed
+// as expired and returns the number of affected commands.
+func (ds *CommandDatastore) ExpirePending(ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ExpirePending")
+	defer span.Finish()
+
+	now := time.Now()
+	qs := ds.db.
+		Model(&Command{}).
+		Where("status IN (?) AND expires_at IS NOT NULL AND expires_at <= ?", []CommandStatus{CommandStatusPending, CommandStatusDelivered}, now).
+		Updates(map[string]interface{}{
+			"status":       CommandStatusExpired,
+			"completed_at": now,
+		})
+	if err := qs.Error; err != nil {
+		return 0, fmt.Errorf("failed to expire commands: %s", err)
+	}
+
+	return qs.RowsAffected, nil
+}
@@ -0,0 +1,93 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/mailru/dbr"
+	"github.com/opentracing/opentracing-go"
+)
+
+type IncidentDatastore struct {
+	db *gorm.DB
+}
+
+func NewIncidentDatastore(db *gorm.DB) (*IncidentDatastore, error) {
+	return &IncidentDatastore{db: db}, nil
+}
+
+func (ds *IncidentDatastore) Create(ctx context.Context, minerID string, condition IncidentCondition, details string, actions []string) (*Incident, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Create")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("condition", condition)
+
+	incident := &Incident{
+		ID:        uuid.New().String(),
+		MinerID:   minerID,
+		Condition: condition,
+		Actions:   dbr.NewNullString(strings.Join(actions, ",")),
+		Details:   dbr.NewNullString(details),
+		CreatedAt: pointer.ToTime(time.Now()),
+	}
+
+	err := ds.db.Create(incident).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create incident: %s", err)
+	}
+
+	return incident, nil
+}
+
+func (ds *IncidentDatastore) List(ctx context.Context, minerID string, fltr *ListFilter) ([]*Incident, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "List")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+
+	incidents := []*Incident{}
+
+	qs := ds.db.Where("miner_id = ?", minerID).Order("created_at DESC")
+	if fltr != nil {
+		if fltr.Limit != nil {
+			qs = qs.Limit(*fltr.Limit)
+		}
+		if fltr.Offset != nil {
+			qs = qs.Offset(*fltr.Offset)
+		}
+	}
+
+	if err := qs.Find(&incidents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %s", err)
+	}
+
+	return incidents, nil
+}
+
+// CountSince returns the number of incidents of the condition recorded for
+// the miner after the given time.
+func (ds *IncidentDatastore) CountSince(ctx context.Context, minerID string, condition IncidentCondition, since time.Time) (int, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CountSince")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("condition", condition)
+
+	count := 0
+	err := ds.db.
+		Model(&Incident{}).
+		Where("miner_id = ? AND `condition` = ? AND created_at > ?", minerID, condition, since).
+		Count(&count).
+		Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count incidents: %s", err)
+	}
+
+	return count, nil
+}
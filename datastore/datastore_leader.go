@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/opentracing/opentracing-go"
+)
+
+type LeaderDatastore struct {
+	db *gorm.DB
+}
+
+func NewLeaderDatastore(db *gorm.DB) (*LeaderDatastore, error) {
+	return &LeaderDatastore{db: db}, nil
+}
+
+// Acquire takes or renews the named lease for the holder when it is free,
+// expired or already held by it, and reports whether the holder owns it.
+// Expiry is computed with the database clock so replicas need not agree on
+// time.
+func (ds *LeaderDatastore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Acquire")
+	defer span.Finish()
+
+	span.SetTag("name", name)
+	span.SetTag("holder", holder)
+
+	seconds := ttl.Seconds()
+	err := ds.db.Exec(
+		"INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, NOW(6) + INTERVAL ? SECOND) "+
+			"ON DUPLICATE KEY UPDATE "+
+			"holder = IF(holder = VALUES(holder) OR expires_at < NOW(6), VALUES(holder), holder), "+
+			"expires_at = IF(holder = VALUES(holder), VALUES(expires_at), expires_at)",
+		name, holder, seconds,
+	).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire leader lease: %s", err)
+	}
+
+	lease := &LeaderLease{}
+	if err := ds.db.Where("name = ?", name).First(lease).Error; err != nil {
+		return false, fmt.Errorf("failed to get leader lease: %s", err)
+	}
+
+	return lease.Holder == holder, nil
+}
+
+// Release gives the lease up so another replica can take over right away.
+func (ds *LeaderDatastore) Release(ctx context.Context, name, holder string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Release")
+	defer span.Finish()
+
+	span.SetTag("name", name)
+	span.SetTag("holder", holder)
+
+	err := ds.db.
+		Model(&LeaderLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		UpdateColumn("expires_at", gorm.Expr("NOW(6)")).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to release leader lease: %s", err)
+	}
+
+	return nil
+}
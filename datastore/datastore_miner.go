@@ -17,7 +17,8 @@ import (
 )
 
 var (
-	ErrMinerNotFound = errors.New("miner is not found")
+	ErrMinerNotFound     = errors.New("miner is not found")
+	ErrMinerNotAvailable = errors.New("miner is not available")
 )
 
 type MinerDatastore struct {
@@ -210,207 +211,995 @@ func (ds *MinerDatastore) ListByOnline(ctx context.Context) ([]*Miner, error) {
 	return miners, nil
 }
 
-func (ds *MinerDatastore) GetInternal(ctx context.Context) (*Miner, error) {
+// GetInternal allocates a free internal miner to the holder with a lease of
+// the given ttl. Miners with a pending force task are allocated first.
+func (ds *MinerDatastore) GetInternal(ctx context.Context, holder string, ttl time.Duration) (*Miner, error) {
 	span, _ := opentracing.StartSpanFromContext(ctx, "GetInternal")
 	defer span.Finish()
 
+	span.SetTag("holder", holder)
+
+	tx := ds.db.Begin()
+
+	now := time.Now()
 	miner := &Miner{}
-	qs := ds.db.
+	qs := tx.
 		Set("gorm:query_option", "FOR UPDATE").
-		Where("status IN (?) AND is_internal = ? AND is_lock = ?", []string{v1.MinerStatusOffline.String(), v1.MinerStatusNew.String()}, true, false).
-		Order("JSON_EXTRACT(tags, \"$.force_task_id\")", true).
+		Where("status IN (?) AND is_internal = ? AND is_block = ? AND is_draining = ?", []string{v1.MinerStatusOffline.String(), v1.MinerStatusNew.String()}, true, false, false).
+		Where("lease_expires_at IS NULL OR lease_expires_at < ?", now).
+		Order(gorm.Expr("EXISTS (SELECT 1 FROM miner_pins WHERE miner_pins.miner_id = miners.id AND miner_pins."+activePinCondition+") DESC", now)).
 		First(&miner)
 	if err := qs.Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get internal miner: %s", err)
+	}
+
+	miner.LeaseHolder = dbr.NewNullString(holder)
+	miner.LeaseAcquiredAt = pointer.ToTime(now)
+	miner.LeaseExpiresAt = pointer.ToTime(now.Add(ttl))
+
+	err := tx.Model(miner).UpdateColumns(map[string]interface{}{
+		"lease_holder":      miner.LeaseHolder,
+		"lease_acquired_at": miner.LeaseAcquiredAt,
+		"lease_expires_at":  miner.LeaseExpiresAt,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to lease miner: %s", err)
+	}
+
+	tx.Commit()
+
+	return miner, nil
+}
+
+// RenewLease extends the lease of the holder. It fails with
+// ErrMinerNotAvailable when the lease belongs to someone else or expired.
+func (ds *MinerDatastore) RenewLease(ctx context.Context, miner *Miner, holder string, ttl time.Duration) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "RenewLease")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("holder", holder)
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	qs := ds.db.
+		Model(&Miner{}).
+		Where("id = ? AND lease_holder = ? AND lease_expires_at > ?", miner.ID, holder, now).
+		UpdateColumn("lease_expires_at", expiresAt)
+	if err := qs.Error; err != nil {
+		return fmt.Errorf("failed to renew lease: %s", err)
+	}
+
+	if qs.RowsAffected == 0 {
+		return ErrMinerNotAvailable
+	}
+
+	miner.LeaseExpiresAt = pointer.ToTime(expiresAt)
+
+	return nil
+}
+
+func (ds *MinerDatastore) ReleaseLease(ctx context.Context, miner *Miner) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ReleaseLease")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	if miner.LeaseExpiresAt == nil {
+		return nil
+	}
+
+	miner.LeaseHolder = dbr.NewNullString(nil)
+	miner.LeaseAcquiredAt = nil
+	miner.LeaseExpiresAt = nil
+
+	err := ds.db.Model(miner).UpdateColumns(map[string]interface{}{
+		"lease_holder":      miner.LeaseHolder,
+		"lease_acquired_at": nil,
+		"lease_expires_at":  nil,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %s", err)
+	}
+
+	return nil
+}
+
+// ReclaimExpiredLeases returns internal miners whose lease expired before
+// the allocated pod registered them back to the pool.
+func (ds *MinerDatastore) ReclaimExpiredLeases(ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ReclaimExpiredLeases")
+	defer span.Finish()
+
+	qs := ds.db.
+		Table("miners").
+		Where("lease_expires_at IS NOT NULL AND lease_expires_at < ?", time.Now()).
+		UpdateColumns(map[string]interface{}{
+			"lease_holder":      nil,
+			"lease_acquired_at": nil,
+			"lease_expires_at":  nil,
+		})
+	if err := qs.Error; err != nil {
+		return 0, fmt.Errorf("failed to reclaim expired leases: %s", err)
+	}
+
+	return qs.RowsAffected, nil
+}
+
+func (ds *MinerDatastore) ListCandidates(ctx context.Context, encode, cpu float64) ([]*Miner, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListCandidates")
+	defer span.Finish()
+
+	miners := []*Miner{}
+
+	qs := ds.db.
+		Where("status = ? AND active_tasks < GREATEST(task_slots, 1) AND is_block = ? AND is_draining = ?", v1.MinerStatusIdle, false, false).
+		Where("quarantined_until IS NULL OR quarantined_until < ?", time.Now()).
+		Where("is_unstable = ?", false).
+		Where("NOT EXISTS (SELECT 1 FROM maintenance_windows WHERE maintenance_windows.miner_id = miners.id AND starts_at <= ? AND ends_at > ?)", time.Now(), time.Now()).
+		Where("IF(verified_at IS NULL, JSON_EXTRACT(capacity_info, '$.encode'), verified_encode_capacity) / GREATEST(task_slots, 1) >= ? AND JSON_EXTRACT(capacity_info, '$.cpu') / GREATEST(task_slots, 1) >= ?", encode, cpu).
+		Where("reserved_until IS NULL OR reserved_until < ?", time.Now()).
+		Find(&miners)
+
+	if err := qs.Error; err != nil {
+		return nil, fmt.Errorf("failed to list candidates: %s", err)
+	}
+
+	return miners, nil
+}
+
+// UpdateLastPingAt records a ping. An offline miner only goes back to Idle
+// after enough consecutive pings, and every transition counts towards
+// marking it as unstable.
+func (ds *MinerDatastore) UpdateLastPingAt(ctx context.Context, miner *Miner, liveness Liveness) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateLastPingAt")
+	defer span.Finish()
+
+	tx := ds.db.Begin()
+
+	now := time.Now()
+	wasOnline := miner.Status == v1.MinerStatusIdle || miner.Status == v1.MinerStatusBusy
+
+	if miner.LastPingAt != nil && now.Sub(*miner.LastPingAt) <= liveness.PingGap {
+		miner.ConsecutivePings++
+	} else {
+		miner.ConsecutivePings = 1
+	}
+	miner.LastPingAt = pointer.ToTime(now)
+
+	updates := map[string]interface{}{
+		"last_ping_at":      miner.LastPingAt,
+		"consecutive_pings": miner.ConsecutivePings,
+	}
+
+	recovered := !wasOnline && miner.ConsecutivePings >= liveness.RecoveryPings
+	if wasOnline || recovered {
+		miner.Status = v1.MinerStatusIdle
+		if miner.ActiveTasks > 0 && miner.FreeSlots() == 0 {
+			miner.Status = v1.MinerStatusBusy
+		}
+		updates["status"] = miner.Status
+
+		if recovered || miner.OnlineAt == nil {
+			miner.OnlineAt = miner.LastPingAt
+			updates["online_at"] = miner.OnlineAt
+		}
+	}
+
+	var unstable bool
+	if recovered {
+		miner.Transitions, unstable = liveness.Transition(miner.Transitions, now)
+	} else {
+		miner.Transitions = miner.Transitions.Since(now.Add(-liveness.Window))
+		unstable = len(miner.Transitions) > liveness.MaxTransitions
+	}
+	if recovered || unstable != miner.IsUnstable {
+		miner.IsUnstable = unstable
+		updates["transitions"] = miner.Transitions
+		updates["is_unstable"] = miner.IsUnstable
+	}
+
+	err := ds.db.Model(&miner).UpdateColumns(updates).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update last_ping_at: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) UpdateSystemInfo(ctx context.Context, miner *Miner, systemInfo Info) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateSystemInfo")
+	defer span.Finish()
+
+	tx := ds.db.Begin()
+
+	miner.SystemInfo = systemInfo
+	err := ds.db.Model(&miner).UpdateColumn("system_info", miner.SystemInfo).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update system_info: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) UpdateGeolocation(ctx context.Context, miner *Miner, geolocation Info) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateGeolocation")
+	defer span.Finish()
+
+	tx := ds.db.Begin()
+	err := ds.db.Exec(
+		"UPDATE miners SET system_info = JSON_SET(system_info, '$.geo', JSON_OBJECT('latitude', ?, 'longitude', ?, 'country', ?, 'continent', ?)) WHERE id = ?;",
+		geolocation["latitude"], geolocation["longitude"], geolocation["country"], geolocation["continent"], miner.ID,
+	).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) UpdateCapacityInfo(ctx context.Context, miner *Miner, capacityInfo Info) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateCapacityInfo")
+	defer span.Finish()
+
+	tx := ds.db.Begin()
+
+	miner.CapacityInfo = capacityInfo
+	miner.TaskSlots = 1
+	if slots, ok := capacityInfo["slots"].(float64); ok && slots >= 1 {
+		miner.TaskSlots = int(slots)
+	}
+
+	err := ds.db.Model(&miner).UpdateColumns(map[string]interface{}{
+		"capacity_info": miner.CapacityInfo,
+		"task_slots":    miner.TaskSlots,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update capacity_info: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// RecordBenchmark stores the encode capacity measured by a benchmark and
+// flags the miner when its claimed capacity exceeds it by more than the
+// tolerance ratio.
+func (ds *MinerDatastore) RecordBenchmark(ctx context.Context, miner *Miner, encode float64, tolerance float64) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "RecordBenchmark")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("encode", encode)
+
+	claimed, _ := miner.CapacityInfo["encode"].(float64)
+	diverged := claimed > encode*(1+tolerance)
+
+	now := time.Now()
+	err := ds.db.Model(miner).UpdateColumns(map[string]interface{}{
+		"verified_encode_capacity": encode,
+		"verified_at":              now,
+		"capacity_diverged":        diverged,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record benchmark: %s", err)
+	}
+
+	miner.VerifiedEncodeCapacity = dbr.NewNullFloat64(encode)
+	miner.VerifiedAt = pointer.ToTime(now)
+	miner.CapacityDiverged = diverged
+
+	return nil
+}
+
+// ListBenchmarkDue returns the online miners never benchmarked or whose last
+// benchmark is older than the interval.
+func (ds *MinerDatastore) ListBenchmarkDue(ctx context.Context, interval time.Duration) ([]*Miner, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListBenchmarkDue")
+	defer span.Finish()
+
+	miners := []*Miner{}
+
+	err := ds.db.
+		Where("status IN (?)", []string{v1.MinerStatusIdle.String(), v1.MinerStatusBusy.String()}).
+		Where("verified_at IS NULL OR verified_at < ?", time.Now().Add(-interval)).
+		Find(&miners).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list miners due for benchmark: %s", err)
+	}
+
+	return miners, nil
+}
+
+func (ds *MinerDatastore) UpdateWorkerInfoByAddress(ctx context.Context, address string, workerInfo *emitterv1.WorkerResponse) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateWorkerInfo")
+	defer span.Finish()
+
+	tx := ds.db.Begin()
+
+	err := ds.db.Model(Miner{}).Where("address = ?", address).UpdateColumn("worker_info", workerInfo).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update worker_info: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) UpdateMinerReward(ctx context.Context, miner *Miner, reward float64) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateMinerReward")
+	defer span.Finish()
+
+	err := ds.db.Model(miner).UpdateColumn("reward", reward).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AssignTask puts the task on the lowest free slot of the miner. Assigning a
+// task the miner already runs is a no-op. It fails with ErrMinerNotAvailable
+// when every slot is taken. The miner becomes busy once all slots are full.
+func (ds *MinerDatastore) AssignTask(ctx context.Context, miner *Miner, taskID string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "AssignTask")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("task_id", taskID)
+
+	tx := ds.db.Begin()
+
+	locked := new(Miner)
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", miner.ID).First(locked).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to lock miner: %s", err)
+	}
+
+	tasks := []*MinerTask{}
+	err = tx.Where("miner_id = ?", miner.ID).Find(&tasks).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to list miner tasks: %s", err)
+	}
+
+	used := map[int]bool{}
+	for _, task := range tasks {
+		if task.TaskID == taskID {
+			tx.Rollback()
+			return nil
+		}
+		used[task.Slot] = true
+	}
+
+	if len(tasks) >= locked.Slots() {
+		tx.Rollback()
+		return ErrMinerNotAvailable
+	}
+
+	slot := 0
+	for used[slot] {
+		slot++
+	}
+
+	task := &MinerTask{
+		MinerID:    miner.ID,
+		TaskID:     taskID,
+		Slot:       slot,
+		AssignedAt: pointer.ToTime(time.Now()),
+	}
+	err = tx.Create(task).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create miner task: %s", err)
+	}
+
+	locked.ActiveTasks = len(tasks) + 1
+	updates := map[string]interface{}{
+		"current_task_id":  dbr.NewNullString(taskID),
+		"active_tasks":     locked.ActiveTasks,
+		"reserved_task_id": dbr.NewNullString(nil),
+		"reserved_until":   nil,
+		"tasks_assigned":   gorm.Expr("tasks_assigned + 1"),
+	}
+	if locked.Status == v1.MinerStatusIdle && locked.FreeSlots() == 0 {
+		updates["status"] = v1.MinerStatusBusy
+		miner.Status = v1.MinerStatusBusy
+	}
+
+	err = tx.Model(locked).UpdateColumns(updates).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update current_task_id: %s", err)
+	}
+
+	tx.Commit()
+
+	miner.CurrentTaskID = dbr.NewNullString(taskID)
+	miner.ActiveTasks = locked.ActiveTasks
+	miner.TaskSlots = locked.TaskSlots
+	miner.ReservedTaskID = dbr.NewNullString(nil)
+	miner.ReservedUntil = nil
+	miner.TasksAssigned++
+
+	return nil
+}
+
+// UnassignTask frees the slot of the task, or every slot when taskID is
+// empty. A busy miner becomes idle again once a slot is free.
+func (ds *MinerDatastore) UnassignTask(ctx context.Context, miner *Miner, taskID string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UnassignTask")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("task_id", taskID)
+
+	tx := ds.db.Begin()
+
+	locked := new(Miner)
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", miner.ID).First(locked).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to lock miner: %s", err)
+	}
+
+	qs := tx.Where("miner_id = ?", miner.ID)
+	if taskID != "" {
+		qs = qs.Where("task_id = ?", taskID)
+	}
+	err = qs.Delete(&MinerTask{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete miner tasks: %s", err)
+	}
+
+	tasks := []*MinerTask{}
+	err = tx.Where("miner_id = ?", miner.ID).Order("assigned_at DESC").Find(&tasks).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to list miner tasks: %s", err)
+	}
+
+	miner.CurrentTaskID = dbr.NewNullString(nil)
+	if len(tasks) > 0 {
+		miner.CurrentTaskID = dbr.NewNullString(tasks[0].TaskID)
+	}
+	miner.ActiveTasks = len(tasks)
+
+	updates := map[string]interface{}{
+		"current_task_id": miner.CurrentTaskID,
+		"active_tasks":    miner.ActiveTasks,
+	}
+	if locked.Status == v1.MinerStatusBusy && len(tasks) < locked.Slots() {
+		updates["status"] = v1.MinerStatusIdle
+		miner.Status = v1.MinerStatusIdle
+	}
+
+	err = tx.Model(locked).UpdateColumns(updates).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update current_task_id: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) ListTasks(ctx context.Context, miner *Miner) ([]*MinerTask, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListTasks")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	tasks := []*MinerTask{}
+
+	err := ds.db.Where("miner_id = ?", miner.ID).Order("slot").Find(&tasks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list miner tasks: %s", err)
+	}
+
+	return tasks, nil
+}
+
+// ListByTasks returns the miners running or reserved for any of the tasks.
+func (ds *MinerDatastore) ListByTasks(ctx context.Context, taskIDs []string) ([]*Miner, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListByTasks")
+	defer span.Finish()
+
+	miners := []*Miner{}
+
+	err := ds.db.
+		Where("id IN (SELECT miner_id FROM miner_tasks WHERE task_id IN (?))", taskIDs).
+		Or("reserved_task_id IN (?) AND reserved_until > ?", taskIDs, time.Now()).
+		Find(&miners).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list miners by tasks: %s", err)
+	}
+
+	return miners, nil
+}
+
+// Reserve atomically reserves an idle miner for the task until the ttl
+// expires. It fails with ErrMinerNotAvailable when the miner is not idle or
+// already holds a live reservation for another task.
+func (ds *MinerDatastore) Reserve(ctx context.Context, miner *Miner, taskID string, ttl time.Duration) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Reserve")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("task_id", taskID)
+
+	now := time.Now()
+	until := now.Add(ttl)
+
+	qs := ds.db.
+		Model(&Miner{}).
+		Where("id = ? AND status = ?", miner.ID, v1.MinerStatusIdle).
+		Where("reserved_until IS NULL OR reserved_until < ? OR reserved_task_id = ?", now, taskID).
+		UpdateColumns(map[string]interface{}{
+			"reserved_task_id": taskID,
+			"reserved_until":   until,
+		})
+	if err := qs.Error; err != nil {
+		return fmt.Errorf("failed to reserve miner: %s", err)
+	}
+
+	if qs.RowsAffected == 0 {
+		return ErrMinerNotAvailable
+	}
+
+	miner.ReservedTaskID = dbr.NewNullString(taskID)
+	miner.ReservedUntil = pointer.ToTime(until)
+
+	return nil
+}
+
+// ReleaseReservations clears the live reservations held for the task and
+// returns the number of affected miners.
+func (ds *MinerDatastore) ReleaseReservations(ctx context.Context, taskID string) (int64, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ReleaseReservations")
+	defer span.Finish()
+
+	span.SetTag("task_id", taskID)
+
+	qs := ds.db.
+		Table("miners").
+		Where("reserved_task_id = ?", taskID).
+		UpdateColumns(map[string]interface{}{
+			"reserved_task_id": nil,
+			"reserved_until":   nil,
+		})
+	if err := qs.Error; err != nil {
+		return 0, fmt.Errorf("failed to release reservations: %s", err)
+	}
+
+	return qs.RowsAffected, nil
+}
+
+// ExpireReservations clears reservations whose lease has passed and returns
+// the number of affected miners.
+func (ds *MinerDatastore) ExpireReservations(ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ExpireReservations")
+	defer span.Finish()
+
+	qs := ds.db.
+		Table("miners").
+		Where("reserved_until IS NOT NULL AND reserved_until < ?", time.Now()).
+		UpdateColumns(map[string]interface{}{
+			"reserved_task_id": nil,
+			"reserved_until":   nil,
+		})
+	if err := qs.Error; err != nil {
+		return 0, fmt.Errorf("failed to expire reservations: %s", err)
+	}
+
+	return qs.RowsAffected, nil
+}
+
+// Block excludes the miner from scheduling until it is unblocked or the ttl
+// expires; a zero ttl blocks it indefinitely.
+func (ds *MinerDatastore) Block(ctx context.Context, miner *Miner, reason, actor string, ttl time.Duration) (*Block, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Block")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("actor", actor)
+
+	tx := ds.db.Begin()
+
+	now := time.Now()
+	block := &Block{
+		ID:        uuid.New().String(),
+		MinerID:   miner.ID,
+		Reason:    dbr.NewNullString(reason),
+		BlockedBy: dbr.NewNullString(actor),
+		BlockedAt: pointer.ToTime(now),
+	}
+	if ttl > 0 {
+		block.ExpiresAt = pointer.ToTime(now.Add(ttl))
+	}
+
+	err := tx.Create(block).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create block: %s", err)
+	}
+
+	err = tx.Model(miner).UpdateColumn("is_block", true).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to block miner: %s", err)
+	}
+
+	tx.Commit()
+
+	miner.IsBlock = true
+
+	return block, nil
+}
+
+func (ds *MinerDatastore) Unblock(ctx context.Context, miner *Miner, actor string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Unblock")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("actor", actor)
+
+	tx := ds.db.Begin()
+
+	err := tx.
+		Model(&Block{}).
+		Where("miner_id = ? AND unblocked_at IS NULL", miner.ID).
+		UpdateColumns(map[string]interface{}{
+			"unblocked_by": dbr.NewNullString(actor),
+			"unblocked_at": time.Now(),
+		}).
+		Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to close blocks: %s", err)
+	}
+
+	err = tx.Model(miner).UpdateColumn("is_block", false).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to unblock miner: %s", err)
+	}
+
+	tx.Commit()
+
+	miner.IsBlock = false
+
+	return nil
+}
+
+// ExpireBlock closes an expired block and unblocks the miner unless another
+// block still applies to it.
+func (ds *MinerDatastore) ExpireBlock(ctx context.Context, block *Block) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ExpireBlock")
+	defer span.Finish()
+
+	span.SetTag("id", block.MinerID)
+
+	tx := ds.db.Begin()
+
+	now := time.Now()
+	err := tx.Model(block).UpdateColumn("unblocked_at", now).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to close block: %s", err)
+	}
+
+	count := 0
+	err = tx.
+		Model(&Block{}).
+		Where("miner_id = ? AND unblocked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", block.MinerID, now).
+		Count(&count).
+		Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to count active blocks: %s", err)
+	}
+
+	if count == 0 {
+		err = tx.Model(&Miner{}).Where("id = ?", block.MinerID).UpdateColumn("is_block", false).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to unblock miner: %s", err)
+		}
+	}
+
+	tx.Commit()
+
+	block.UnblockedAt = pointer.ToTime(now)
+
+	return nil
+}
+
+// GetActiveBlock returns the block currently applied to the miner, if any.
+func (ds *MinerDatastore) GetActiveBlock(ctx context.Context, miner *Miner) (*Block, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "GetActiveBlock")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	block := new(Block)
+	err := ds.db.
+		Where("miner_id = ? AND unblocked_at IS NULL", miner.ID).
+		Order("blocked_at DESC").
+		First(block).
+		Error
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, err
+			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to get internal miner: %s", err)
+		return nil, fmt.Errorf("failed to get active block: %s", err)
 	}
 
-	err := ds.db.Model(miner).Update("is_lock", true).Error
+	return block, nil
+}
+
+func (ds *MinerDatastore) ListBlocks(ctx context.Context, minerID string) ([]*Block, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListBlocks")
+	defer span.Finish()
+
+	span.SetTag("id", minerID)
+
+	blocks := []*Block{}
+
+	err := ds.db.Where("miner_id = ?", minerID).Order("blocked_at DESC").Find(&blocks).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to lock miner: %s", err)
+		return nil, fmt.Errorf("failed to list blocks: %s", err)
 	}
 
-	return miner, nil
+	return blocks, nil
 }
 
-func (ds *MinerDatastore) ListByTag(ctx context.Context, tag, value string) ([]*Miner, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "ListByTag")
+func (ds *MinerDatastore) CountBlocksSince(ctx context.Context, minerID string, since time.Time) (int, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CountBlocksSince")
 	defer span.Finish()
 
-	miners := []*Miner{}
-
-	qs := ds.db
-	if tag != "" && value != "" {
-		qs = qs.Where("JSON_EXTRACT(tags, '$.force_task_id') = ?", value)
-	}
-	qs = qs.Find(&miners)
+	span.SetTag("id", minerID)
 
-	if err := qs.Error; err != nil {
-		return nil, fmt.Errorf("failed to get miners list: %s", err)
+	count := 0
+	err := ds.db.
+		Model(&Block{}).
+		Where("miner_id = ? AND blocked_at > ?", minerID, since).
+		Count(&count).
+		Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count blocks: %s", err)
 	}
 
-	return miners, nil
+	return count, nil
 }
 
-func (ds *MinerDatastore) ListCandidates(ctx context.Context, encode, cpu float64) ([]*Miner, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "ListCandidates")
+func (ds *MinerDatastore) UpdateReputation(ctx context.Context, miner *Miner, reputation Reputation) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateReputation")
 	defer span.Finish()
 
-	miners := []*Miner{}
-
-	qs := ds.db.
-		Where("status = ? AND JSON_EXTRACT(capacity_info, '$.encode') >= ? AND JSON_EXTRACT(capacity_info, '$.cpu') >= ?", v1.MinerStatusIdle, encode, cpu).
-		Find(&miners)
+	span.SetTag("id", miner.ID)
 
-	if err := qs.Error; err != nil {
-		return nil, fmt.Errorf("failed to list candidates: %s", err)
+	score := reputation.Score()
+	err := ds.db.Model(miner).UpdateColumns(map[string]interface{}{
+		"reputation":       reputation,
+		"reputation_score": score,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update reputation: %s", err)
 	}
 
-	return miners, nil
+	miner.Reputation = reputation
+	miner.ReputationScore = dbr.NewNullFloat64(score)
+
+	return nil
 }
 
-func (ds *MinerDatastore) UpdateLastPingAt(ctx context.Context, miner *Miner) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateLastPingAt")
+// ListExpiredBlocks returns the open blocks whose expiry has passed.
+func (ds *MinerDatastore) ListExpiredBlocks(ctx context.Context) ([]*Block, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListExpiredBlocks")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
+	blocks := []*Block{}
 
-	miner.LastPingAt = pointer.ToTime(time.Now())
-	err := ds.db.Model(&miner).UpdateColumn("last_ping_at", miner.LastPingAt).Error
+	err := ds.db.
+		Where("unblocked_at IS NULL AND expires_at IS NOT NULL AND expires_at < ?", time.Now()).
+		Find(&blocks).
+		Error
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update last_ping_at: %s", err)
+		return nil, fmt.Errorf("failed to list expired blocks: %s", err)
 	}
 
-	miner.Status = v1.MinerStatusIdle
+	return blocks, nil
+}
+
+// Drain stops the miner from receiving new tasks while it finishes the
+// running ones. Undrain returns it to scheduling.
+func (ds *MinerDatastore) Drain(ctx context.Context, miner *Miner) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Drain")
+	defer span.Finish()
 
-	if miner.CurrentTaskID.String != "" {
-		miner.Status = v1.MinerStatusBusy
+	span.SetTag("id", miner.ID)
+
+	if miner.IsDraining {
+		return nil
 	}
 
-	err = ds.db.Model(&miner).UpdateColumn("status", miner.Status).Error
+	now := time.Now()
+	err := ds.db.Model(miner).UpdateColumns(map[string]interface{}{
+		"is_draining":        true,
+		"drain_requested_at": now,
+	}).Error
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update last_ping_at: %s", err)
+		return fmt.Errorf("failed to drain miner: %s", err)
 	}
 
-	tx.Commit()
+	miner.IsDraining = true
+	miner.DrainRequestedAt = pointer.ToTime(now)
 
 	return nil
 }
 
-func (ds *MinerDatastore) UpdateSystemInfo(ctx context.Context, miner *Miner, systemInfo Info) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateSystemInfo")
+func (ds *MinerDatastore) Undrain(ctx context.Context, miner *Miner) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Undrain")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
+	span.SetTag("id", miner.ID)
 
-	miner.SystemInfo = systemInfo
-	err := ds.db.Model(&miner).UpdateColumn("system_info", miner.SystemInfo).Error
+	err := ds.db.Model(miner).UpdateColumns(map[string]interface{}{
+		"is_draining":        false,
+		"drain_requested_at": nil,
+	}).Error
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update system_info: %s", err)
+		return fmt.Errorf("failed to undrain miner: %s", err)
 	}
 
-	tx.Commit()
+	miner.IsDraining = false
+	miner.DrainRequestedAt = nil
 
 	return nil
 }
 
-func (ds *MinerDatastore) UpdateGeolocation(ctx context.Context, miner *Miner, geolocation Info) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateGeolocation")
+func (ds *MinerDatastore) ListDraining(ctx context.Context) ([]*Miner, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListDraining")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
-	err := ds.db.Exec("UPDATE miners SET system_info = JSON_SET(system_info, '$.geo', JSON_OBJECT('latitude', ?, 'longitude', ?)) WHERE id = ?;", geolocation["latitude"], geolocation["longitude"], miner.ID).Error
+	miners := []*Miner{}
+
+	err := ds.db.Where("is_draining = ?", true).Find(&miners).Error
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, fmt.Errorf("failed to list draining miners: %s", err)
 	}
 
-	tx.Commit()
-
-	return nil
+	return miners, nil
 }
 
-func (ds *MinerDatastore) UpdateCapacityInfo(ctx context.Context, miner *Miner, capacityInfo Info) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateCapacityInfo")
+func (ds *MinerDatastore) CreateMaintenanceWindow(ctx context.Context, miner *Miner, startsAt, endsAt time.Time, createdBy string) (*MaintenanceWindow, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CreateMaintenanceWindow")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
+	span.SetTag("id", miner.ID)
 
-	miner.CapacityInfo = capacityInfo
-	err := ds.db.Model(&miner).UpdateColumn("capacity_info", miner.CapacityInfo).Error
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update capacity_info: %s", err)
+	if !endsAt.After(startsAt) {
+		return nil, errors.New("maintenance window must end after it starts")
 	}
 
-	tx.Commit()
+	window := &MaintenanceWindow{
+		ID:        uuid.New().String(),
+		MinerID:   miner.ID,
+		StartsAt:  pointer.ToTime(startsAt),
+		EndsAt:    pointer.ToTime(endsAt),
+		CreatedBy: dbr.NewNullString(createdBy),
+		CreatedAt: pointer.ToTime(time.Now()),
+	}
 
-	return nil
+	err := ds.db.Create(window).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create maintenance window: %s", err)
+	}
+
+	return window, nil
 }
 
-func (ds *MinerDatastore) UpdateWorkerInfoByAddress(ctx context.Context, address string, workerInfo *emitterv1.WorkerResponse) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateWorkerInfo")
+func (ds *MinerDatastore) DeleteMaintenanceWindow(ctx context.Context, minerID, id string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "DeleteMaintenanceWindow")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
+	span.SetTag("id", minerID)
 
-	err := ds.db.Model(Miner{}).Where("address = ?", address).UpdateColumn("worker_info", workerInfo).Error
+	err := ds.db.Where("id = ? AND miner_id = ?", id, minerID).Delete(&MaintenanceWindow{}).Error
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update worker_info: %s", err)
+		return fmt.Errorf("failed to delete maintenance window: %s", err)
 	}
 
-	tx.Commit()
-
 	return nil
 }
 
-func (ds *MinerDatastore) UpdateMinerReward(ctx context.Context, miner *Miner, reward float64) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateMinerReward")
+// ListMaintenanceWindows returns the windows of the miner that have not ended.
+func (ds *MinerDatastore) ListMaintenanceWindows(ctx context.Context, minerID string) ([]*MaintenanceWindow, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListMaintenanceWindows")
 	defer span.Finish()
 
-	err := ds.db.Model(miner).UpdateColumn("reward", reward).Error
+	span.SetTag("id", minerID)
+
+	windows := []*MaintenanceWindow{}
+
+	err := ds.db.
+		Where("miner_id = ? AND ends_at > ?", minerID, time.Now()).
+		Order("starts_at").
+		Find(&windows).
+		Error
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to list maintenance windows: %s", err)
 	}
 
-	return nil
+	return windows, nil
 }
 
-func (ds *MinerDatastore) UpdateCurrentTask(ctx context.Context, miner *Miner, taskID string, clearForceTask bool) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateCurrentTask")
+// ListInMaintenance returns the ids of miners inside a maintenance window.
+func (ds *MinerDatastore) ListInMaintenance(ctx context.Context) (map[string]bool, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListInMaintenance")
 	defer span.Finish()
 
-	tx := ds.db.Begin()
+	ids := map[string]bool{}
 
-	updateForceTask := false
-	if taskID == "" {
-		if clearForceTask {
-			updateForceTask = true
-			delete(miner.Tags, "force_task_id")
-		}
-		miner.CurrentTaskID = dbr.NewNullString(nil)
-	} else {
-		miner.CurrentTaskID = dbr.NewNullString(taskID)
+	now := time.Now()
+	rows, err := ds.db.
+		Model(&MaintenanceWindow{}).
+		Select("DISTINCT miner_id").
+		Where("starts_at <= ? AND ends_at > ?", now, now).
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list miners in maintenance: %s", err)
 	}
+	defer rows.Close()
 
-	updates := map[string]interface{}{"current_task_id": miner.CurrentTaskID}
-	if updateForceTask {
-		updates["tags"] = miner.Tags
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to list miners in maintenance: %s", err)
+		}
+		ids[id] = true
 	}
 
-	err := ds.db.Model(&miner).Updates(updates).Error
+	return ids, nil
+}
+
+func (ds *MinerDatastore) IncrementTasksLost(ctx context.Context, miner *Miner) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "IncrementTasksLost")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	err := ds.db.Model(miner).UpdateColumn("tasks_lost", gorm.Expr("tasks_lost + 1")).Error
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update current_task_id: %s", err)
+		return fmt.Errorf("failed to increment tasks_lost: %s", err)
 	}
 
-	tx.Commit()
+	miner.TasksLost++
 
 	return nil
 }
@@ -521,6 +1310,110 @@ func (ds *MinerDatastore) Update(ctx context.Context, miner *Miner, updates map[
 	return nil
 }
 
+func (ds *MinerDatastore) UpdateVersion(ctx context.Context, miner *Miner, version string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateVersion")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("version", version)
+
+	if version == "" || miner.Version.String == version {
+		return nil
+	}
+
+	tx := ds.db.Begin()
+
+	miner.Version = dbr.NewNullString(version)
+
+	err := tx.Model(&miner).UpdateColumn("version", miner.Version).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update version: %s", err)
+	}
+
+	history := &MinerVersion{
+		MinerID:   miner.ID,
+		Version:   version,
+		CreatedAt: pointer.ToTime(time.Now()),
+	}
+
+	err = tx.Create(history).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create version history: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *MinerDatastore) ListVersionHistory(ctx context.Context, minerID string) ([]*MinerVersion, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListVersionHistory")
+	defer span.Finish()
+
+	span.SetTag("id", minerID)
+
+	versions := []*MinerVersion{}
+
+	err := ds.db.Where("miner_id = ?", minerID).Order("created_at DESC").Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list version history: %s", err)
+	}
+
+	return versions, nil
+}
+
+func (ds *MinerDatastore) CountByVersion(ctx context.Context) (map[string]int, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CountByVersion")
+	defer span.Finish()
+
+	counts := map[string]int{}
+
+	rows, err := ds.db.
+		Model(&Miner{}).
+		Select("IFNULL(version, ''), COUNT(*)").
+		Group("version").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count miners by version: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version string
+			count   int
+		)
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, fmt.Errorf("failed to count miners by version: %s", err)
+		}
+		counts[version] = count
+	}
+
+	return counts, nil
+}
+
+func (ds *MinerDatastore) UpdateReleaseChannel(ctx context.Context, miner *Miner, channel ReleaseChannel) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateReleaseChannel")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+	span.SetTag("channel", channel)
+
+	miner.ReleaseChannel = dbr.NewNullString(string(channel))
+	if channel == "" {
+		miner.ReleaseChannel = dbr.NewNullString(nil)
+	}
+
+	err := ds.db.Model(&miner).UpdateColumn("release_channel", miner.ReleaseChannel).Error
+	if err != nil {
+		return fmt.Errorf("failed to update release_channel: %s", err)
+	}
+
+	return nil
+}
+
 func (ds *MinerDatastore) MarkAllAsOffline(ctx context.Context) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "MarkAllAsOffline")
 	defer span.Finish()
@@ -544,36 +1437,104 @@ func (ds *MinerDatastore) MarkAllAsOffline(ctx context.Context) error {
 	return nil
 }
 
-func (ds *MinerDatastore) MarkMinerAsIdle(ctx context.Context, miner *Miner) error {
+// MarkMinerAsIdle brings a registering miner online. Registering counts as a
+// transition, so miners restarting over and over become unstable like the
+// ones that keep missing pings.
+func (ds *MinerDatastore) MarkMinerAsIdle(ctx context.Context, miner *Miner, liveness Liveness) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "MarkMinerAsIdle")
 	defer span.Finish()
 
+	now := time.Now()
+	transitions, unstable := liveness.Transition(miner.Transitions, now)
+
 	err := ds.db.Model(miner).Updates(map[string]interface{}{
-		"status":       v1.MinerStatusIdle,
-		"last_ping_at": time.Now(),
+		"status":            v1.MinerStatusIdle,
+		"last_ping_at":      now,
+		"online_at":         now,
+		"consecutive_pings": 1,
+		"transitions":       transitions,
+		"is_unstable":       unstable,
 	}).Error
 	if err != nil {
 		return err
 	}
 
+	miner.Transitions = transitions
+	miner.IsUnstable = unstable
+
 	return nil
 }
 
-func (ds *MinerDatastore) MarkAsOffline(ctx context.Context, d time.Duration) error {
+// MarkAsOffline marks idle miners that stopped pinging as offline and
+// returns them as they were before the update.
+func (ds *MinerDatastore) MarkAsOffline(ctx context.Context, d time.Duration, liveness Liveness) ([]*Miner, error) {
 	span, _ := opentracing.StartSpanFromContext(ctx, "MarkAsOffline")
 	defer span.Finish()
 
 	t := time.Now().Add(-d * 2)
+	miners := []*Miner{}
 
-	err := ds.db.
-		Table("miners").
-		Where("last_ping_at < ? AND status = ?", t, v1.MinerStatusIdle).
-		Updates(map[string]interface{}{"status": v1.MinerStatusOffline}).
-		Error
+	err := ds.db.Where("last_ping_at < ? AND status = ?", t, v1.MinerStatusIdle).Find(&miners).Error
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for _, miner := range miners {
+		transitions, unstable := liveness.Transition(miner.Transitions, time.Now())
+
+		err = ds.db.
+			Table("miners").
+			Where("id = ? AND status = ?", miner.ID, v1.MinerStatusIdle).
+			Updates(map[string]interface{}{
+				"status":            v1.MinerStatusOffline,
+				"online_at":         nil,
+				"consecutive_pings": 0,
+				"transitions":       transitions,
+				"is_unstable":       unstable,
+			}).
+			Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return miners, nil
+}
+
+func (ds *MinerDatastore) Quarantine(ctx context.Context, miner *Miner, d time.Duration) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Quarantine")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	until := time.Now().Add(d)
+	if miner.QuarantinedUntil != nil && miner.QuarantinedUntil.After(until) {
+		return nil
+	}
+
+	err := ds.db.Model(miner).UpdateColumn("quarantined_until", until).Error
+	if err != nil {
+		return fmt.Errorf("failed to quarantine miner: %s", err)
+	}
+
+	miner.QuarantinedUntil = pointer.ToTime(until)
+
+	return nil
+}
+
+func (ds *MinerDatastore) UpdateAvailability(ctx context.Context, miner *Miner, schedule Schedule) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateAvailability")
+	defer span.Finish()
+
+	span.SetTag("id", miner.ID)
+
+	err := ds.db.Model(miner).UpdateColumn("availability", schedule).Error
+	if err != nil {
+		return fmt.Errorf("failed to update availability: %s", err)
 	}
 
+	miner.Availability = schedule
+
 	return nil
 }
 
@@ -617,48 +1578,34 @@ func (ds *MinerDatastore) SetTags(ctx context.Context, miner *Miner, tags []*v1.
 	return nil
 }
 
-func (ds *MinerDatastore) GetForceTaskIDs(ctx context.Context) ([]string, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "GetForceTaskIDs")
+// MarkMinerAsOffline takes a miner offline, recording the transition when it
+// was online so that the hysteresis of UpdateLastPingAt applies to its
+// recovery.
+func (ds *MinerDatastore) MarkMinerAsOffline(ctx context.Context, miner *Miner, liveness Liveness) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "MarkMinerAsOffline")
 	defer span.Finish()
 
-	ids := []string{}
-	rows, err := ds.db.Model(&Miner{}).Select("JSON_UNQUOTE(JSON_EXTRACT(tags, '$.force_task_id'))").Rows()
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to get force task ids: %s", err)
+	updates := map[string]interface{}{
+		"status":            v1.MinerStatusOffline,
+		"online_at":         nil,
+		"consecutive_pings": 0,
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id string
-		err := rows.Scan(&id)
-		if err == nil {
-			if id != "" {
-				ids = append(ids, id)
-			}
-		}
+	if miner.Status == v1.MinerStatusIdle || miner.Status == v1.MinerStatusBusy {
+		transitions, unstable := liveness.Transition(miner.Transitions, time.Now())
+		updates["transitions"] = transitions
+		updates["is_unstable"] = unstable
 	}
 
-	return ids, nil
-}
-
-func (ds *MinerDatastore) MarkMinerAsOffline(ctx context.Context, miner *Miner) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "MarkMinerAsOffline")
-	defer span.Finish()
-
-	tx := ds.db.Begin()
-
 	err := ds.db.
 		Table("miners").
 		Where("id = ?", miner.ID).
-		Updates(map[string]interface{}{"status": v1.MinerStatusOffline}).
+		Updates(updates).
 		Error
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	tx.Commit()
-
 	return nil
 }
 
@@ -698,25 +1645,10 @@ func (ds *MinerDatastore) GetStuckOfflineMinerList(ctx context.Context, d time.D
 	t := time.Now().Add(-d * 2)
 	miners := []*Miner{}
 
-	err := ds.db.Where("last_ping_at < ? AND status = ? AND current_task_id IS NOT NULL", t, v1.MinerStatusOffline).Find(&miners).Error
+	err := ds.db.Where("last_ping_at < ? AND status = ? AND active_tasks > 0", t, v1.MinerStatusOffline).Find(&miners).Error
 	if err != nil {
 		return nil, err
 	}
 
 	return miners, nil
 }
-
-func (ds *MinerDatastore) Unlock(ctx context.Context, miner *Miner) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "Unlock")
-	defer span.Finish()
-
-	if miner.IsLock {
-		miner.IsLock = false
-		err := ds.db.Model(&miner).UpdateColumn("is_lock", miner.IsLock).Error
-		if err != nil {
-			return fmt.Errorf("failed to unlock: %s", err)
-		}
-	}
-
-	return nil
-}
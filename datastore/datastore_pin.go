@@ -0,0 +1,193 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/mailru/dbr"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	ErrPinNotFound = errors.New("pin is not found")
+)
+
+const activePinCondition = "cancelled_at IS NULL AND (expires_at IS NULL OR expires_at > ?)"
+
+type PinDatastore struct {
+	db *gorm.DB
+}
+
+func NewPinDatastore(db *gorm.DB) (*PinDatastore, error) {
+	return &PinDatastore{db: db}, nil
+}
+
+// Create pins the task to the miner, replacing any active pin of the miner.
+func (ds *PinDatastore) Create(ctx context.Context, minerID, taskID, createdBy, reason string, ttl time.Duration) (*Pin, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Create")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("task_id", taskID)
+
+	tx := ds.db.Begin()
+
+	now := time.Now()
+	err := tx.
+		Model(&Pin{}).
+		Where("miner_id = ?", minerID).
+		Where(activePinCondition, now).
+		UpdateColumn("cancelled_at", now).
+		Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to cancel previous pins: %s", err)
+	}
+
+	pin := &Pin{
+		ID:        uuid.New().String(),
+		MinerID:   minerID,
+		TaskID:    taskID,
+		CreatedBy: dbr.NewNullString(createdBy),
+		Reason:    dbr.NewNullString(reason),
+		CreatedAt: pointer.ToTime(now),
+	}
+
+	if ttl > 0 {
+		pin.ExpiresAt = pointer.ToTime(now.Add(ttl))
+	}
+
+	err = tx.Create(pin).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create pin: %s", err)
+	}
+
+	tx.Commit()
+
+	return pin, nil
+}
+
+func (ds *PinDatastore) Get(ctx context.Context, id string) (*Pin, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Get")
+	defer span.Finish()
+
+	span.SetTag("id", id)
+
+	pin := new(Pin)
+	if err := ds.db.Where("id = ?", id).First(pin).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrPinNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get pin by id: %s", err)
+	}
+
+	return pin, nil
+}
+
+func (ds *PinDatastore) ListActive(ctx context.Context) ([]*Pin, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListActive")
+	defer span.Finish()
+
+	pins := []*Pin{}
+
+	err := ds.db.Where(activePinCondition, time.Now()).Order("created_at").Find(&pins).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active pins: %s", err)
+	}
+
+	return pins, nil
+}
+
+func (ds *PinDatastore) GetActiveByMiner(ctx context.Context, minerID string) (*Pin, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "GetActiveByMiner")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+
+	pin := new(Pin)
+	err := ds.db.
+		Where("miner_id = ?", minerID).
+		Where(activePinCondition, time.Now()).
+		Order("created_at DESC").
+		First(pin).
+		Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrPinNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get active pin: %s", err)
+	}
+
+	return pin, nil
+}
+
+func (ds *PinDatastore) ListActiveByTask(ctx context.Context, taskID string) ([]*Pin, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListActiveByTask")
+	defer span.Finish()
+
+	span.SetTag("task_id", taskID)
+
+	pins := []*Pin{}
+
+	err := ds.db.
+		Where("task_id = ?", taskID).
+		Where(activePinCondition, time.Now()).
+		Find(&pins).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active pins by task: %s", err)
+	}
+
+	return pins, nil
+}
+
+func (ds *PinDatastore) Cancel(ctx context.Context, pin *Pin) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Cancel")
+	defer span.Finish()
+
+	span.SetTag("id", pin.ID)
+
+	now := time.Now()
+	err := ds.db.Model(pin).UpdateColumn("cancelled_at", now).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel pin: %s", err)
+	}
+
+	pin.CancelledAt = pointer.ToTime(now)
+
+	return nil
+}
+
+// CancelByMiner cancels the active pins of the miner, limited to the task
+// when taskID is not empty.
+func (ds *PinDatastore) CancelByMiner(ctx context.Context, minerID, taskID string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CancelByMiner")
+	defer span.Finish()
+
+	span.SetTag("miner_id", minerID)
+	span.SetTag("task_id", taskID)
+
+	now := time.Now()
+	qs := ds.db.
+		Model(&Pin{}).
+		Where("miner_id = ?", minerID).
+		Where(activePinCondition, now)
+	if taskID != "" {
+		qs = qs.Where("task_id = ?", taskID)
+	}
+
+	err := qs.UpdateColumn("cancelled_at", now).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel pins: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/mailru/dbr"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	ErrConfigProfileNotFound = errors.New("config profile is not found")
+)
+
+type ConfigProfileDatastore struct {
+	db *gorm.DB
+}
+
+func NewConfigProfileDatastore(db *gorm.DB) (*ConfigProfileDatastore, error) {
+	return &ConfigProfileDatastore{db: db}, nil
+}
+
+func (ds *ConfigProfileDatastore) Create(ctx context.Context, name string, schemaVersion int, document Info) (*ConfigProfile, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Create")
+	defer span.Finish()
+
+	span.SetTag("name", name)
+
+	now := time.Now()
+	profile := &ConfigProfile{
+		ID:            uuid.New().String(),
+		Name:          name,
+		SchemaVersion: schemaVersion,
+		Revision:      1,
+		Document:      document,
+		CreatedAt:     pointer.ToTime(now),
+		UpdatedAt:     pointer.ToTime(now),
+	}
+
+	err := ds.db.Create(profile).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config profile: %s", err)
+	}
+
+	return profile, nil
+}
+
+func (ds *ConfigProfileDatastore) GetByName(ctx context.Context, name string) (*ConfigProfile, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "GetByName")
+	defer span.Finish()
+
+	span.SetTag("name", name)
+
+	profile := new(ConfigProfile)
+	if err := ds.db.Where("name = ?", name).First(profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrConfigProfileNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get config profile by name: %s", err)
+	}
+
+	return profile, nil
+}
+
+func (ds *ConfigProfileDatastore) List(ctx context.Context) ([]*ConfigProfile, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "List")
+	defer span.Finish()
+
+	profiles := []*ConfigProfile{}
+
+	err := ds.db.Order("name").Find(&profiles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config profiles: %s", err)
+	}
+
+	return profiles, nil
+}
+
+// UpdateDocument replaces the profile document and bumps its revision so
+// that agents can tell the effective configuration has changed.
+func (ds *ConfigProfileDatastore) UpdateDocument(ctx context.Context, profile *ConfigProfile, schemaVersion int, document Info) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdateDocument")
+	defer span.Finish()
+
+	span.SetTag("id", profile.ID)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"schema_version": schemaVersion,
+		"document":       document,
+		"revision":       gorm.Expr("revision + 1"),
+		"updated_at":     now,
+	}
+
+	err := ds.db.Model(profile).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update config profile: %s", err)
+	}
+
+	profile.SchemaVersion = schemaVersion
+	profile.Document = document
+	profile.Revision++
+	profile.UpdatedAt = pointer.ToTime(now)
+
+	return nil
+}
+
+func (ds *ConfigProfileDatastore) Delete(ctx context.Context, profile *ConfigProfile) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Delete")
+	defer span.Finish()
+
+	span.SetTag("id", profile.ID)
+
+	tx := ds.db.Begin()
+
+	err := tx.Where("profile_id = ?", profile.ID).Delete(&ConfigProfileAssignment{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete config profile assignments: %s", err)
+	}
+
+	err = tx.Delete(profile).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete config profile: %s", err)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func (ds *ConfigProfileDatastore) AssignToMiner(ctx context.Context, profile *ConfigProfile, minerID string) (*ConfigProfileAssignment, error) {
+	return ds.assign(ctx, &ConfigProfileAssignment{
+		ProfileID: profile.ID,
+		MinerID:   dbr.NewNullString(minerID),
+	})
+}
+
+func (ds *ConfigProfileDatastore) AssignToUser(ctx context.Context, profile *ConfigProfile, userID string) (*ConfigProfileAssignment, error) {
+	return ds.assign(ctx, &ConfigProfileAssignment{
+		ProfileID: profile.ID,
+		UserID:    dbr.NewNullString(userID),
+	})
+}
+
+func (ds *ConfigProfileDatastore) AssignToSelector(ctx context.Context, profile *ConfigProfile, selector Tags, priority int) (*ConfigProfileAssignment, error) {
+	return ds.assign(ctx, &ConfigProfileAssignment{
+		ProfileID: profile.ID,
+		Selector:  selector,
+		Priority:  priority,
+	})
+}
+
+func (ds *ConfigProfileDatastore) assign(ctx context.Context, assignment *ConfigProfileAssignment) (*ConfigProfileAssignment, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Assign")
+	defer span.Finish()
+
+	span.SetTag("profile_id", assignment.ProfileID)
+
+	assignment.ID = uuid.New().String()
+	assignment.CreatedAt = pointer.ToTime(time.Now())
+
+	err := ds.db.Create(assignment).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign config profile: %s", err)
+	}
+
+	return assignment, nil
+}
+
+func (ds *ConfigProfileDatastore) ListAssignments(ctx context.Context, profile *ConfigProfile) ([]*ConfigProfileAssignment, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListAssignments")
+	defer span.Finish()
+
+	span.SetTag("profile_id", profile.ID)
+
+	assignments := []*ConfigProfileAssignment{}
+
+	err := ds.db.Where("profile_id = ?", profile.ID).Order("created_at").Find(&assignments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config profile assignments: %s", err)
+	}
+
+	return assignments, nil
+}
+
+func (ds *ConfigProfileDatastore) Unassign(ctx context.Context, assignmentID string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Unassign")
+	defer span.Finish()
+
+	span.SetTag("id", assignmentID)
+
+	err := ds.db.Where("id = ?", assignmentID).Delete(&ConfigProfileAssignment{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to unassign config profile: %s", err)
+	}
+
+	return nil
+}
+
+// Effective returns the profile that applies to the miner, or nil when none
+// is assigned. Miner assignments take precedence over tag selectors, which
+// take precedence over user assignments; ties are broken by priority.
+func (ds *ConfigProfileDatastore) Effective(ctx context.Context, miner *Miner) (*ConfigProfile, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Effective")
+	defer span.Finish()
+
+	span.SetTag("miner_id", miner.ID)
+
+	assignments := []*ConfigProfileAssignment{}
+	err := ds.db.
+		Where("miner_id = ? OR user_id = ? OR selector IS NOT NULL", miner.ID, miner.UserID).
+		Order("priority DESC, created_at DESC").
+		Find(&assignments).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config profile assignments: %s", err)
+	}
+
+	var effective *ConfigProfileAssignment
+	for _, assignment := range assignments {
+		if !assignment.Matches(miner) {
+			continue
+		}
+		if effective == nil || assignment.rank() > effective.rank() {
+			effective = assignment
+		}
+	}
+
+	if effective == nil {
+		return nil, nil
+	}
+
+	profile := new(ConfigProfile)
+	if err := ds.db.Where("id = ?", effective.ProfileID).First(profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get config profile: %s", err)
+	}
+
+	return profile, nil
+}
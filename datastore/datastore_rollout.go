@@ -0,0 +1,195 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/mailru/dbr"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	ErrRolloutNotFound = errors.New("rollout is not found")
+)
+
+type RolloutDatastore struct {
+	db *gorm.DB
+}
+
+func NewRolloutDatastore(db *gorm.DB) (*RolloutDatastore, error) {
+	return &RolloutDatastore{db: db}, nil
+}
+
+func (ds *RolloutDatastore) Create(ctx context.Context, channel ReleaseChannel, targetVersion string, percentage int, hw string) (*Rollout, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Create")
+	defer span.Finish()
+
+	span.SetTag("channel", channel)
+	span.SetTag("target_version", targetVersion)
+
+	now := time.Now()
+	rollout := &Rollout{
+		ID:            uuid.New().String(),
+		Channel:       channel,
+		TargetVersion: targetVersion,
+		Percentage:    percentage,
+		Hw:            dbr.NewNullString(hw),
+		IsActive:      true,
+		CreatedAt:     pointer.ToTime(now),
+		UpdatedAt:     pointer.ToTime(now),
+	}
+
+	err := ds.db.Create(rollout).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollout: %s", err)
+	}
+
+	return rollout, nil
+}
+
+func (ds *RolloutDatastore) Get(ctx context.Context, id string) (*Rollout, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Get")
+	defer span.Finish()
+
+	span.SetTag("id", id)
+
+	rollout := new(Rollout)
+	if err := ds.db.Where("id = ?", id).First(rollout).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRolloutNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get rollout by id: %s", err)
+	}
+
+	return rollout, nil
+}
+
+func (ds *RolloutDatastore) ListActive(ctx context.Context) ([]*Rollout, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListActive")
+	defer span.Finish()
+
+	rollouts := []*Rollout{}
+
+	err := ds.db.Where("is_active = ?", true).Order("created_at DESC").Find(&rollouts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active rollouts: %s", err)
+	}
+
+	return rollouts, nil
+}
+
+func (ds *RolloutDatastore) UpdatePercentage(ctx context.Context, rollout *Rollout, percentage int) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "UpdatePercentage")
+	defer span.Finish()
+
+	span.SetTag("id", rollout.ID)
+	span.SetTag("percentage", percentage)
+
+	updates := map[string]interface{}{
+		"percentage": percentage,
+		"updated_at": time.Now(),
+	}
+
+	err := ds.db.Model(rollout).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update rollout percentage: %s", err)
+	}
+
+	return nil
+}
+
+func (ds *RolloutDatastore) Deactivate(ctx context.Context, rollout *Rollout) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Deactivate")
+	defer span.Finish()
+
+	span.SetTag("id", rollout.ID)
+
+	updates := map[string]interface{}{
+		"is_active":  false,
+		"updated_at": time.Now(),
+	}
+
+	err := ds.db.Model(rollout).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to deactivate rollout: %s", err)
+	}
+
+	return nil
+}
+
+func (ds *RolloutDatastore) CreateChannelSelector(ctx context.Context, channel ReleaseChannel, selector Tags, priority int) (*ReleaseChannelSelector, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CreateChannelSelector")
+	defer span.Finish()
+
+	span.SetTag("channel", channel)
+
+	cs := &ReleaseChannelSelector{
+		ID:        uuid.New().String(),
+		Channel:   channel,
+		Selector:  selector,
+		Priority:  priority,
+		CreatedAt: pointer.ToTime(time.Now()),
+	}
+
+	err := ds.db.Create(cs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel selector: %s", err)
+	}
+
+	return cs, nil
+}
+
+func (ds *RolloutDatastore) ListChannelSelectors(ctx context.Context) ([]*ReleaseChannelSelector, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "ListChannelSelectors")
+	defer span.Finish()
+
+	selectors := []*ReleaseChannelSelector{}
+
+	err := ds.db.Order("priority DESC").Find(&selectors).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel selectors: %s", err)
+	}
+
+	return selectors, nil
+}
+
+func (ds *RolloutDatastore) Channel(ctx context.Context, miner *Miner) (ReleaseChannel, error) {
+	selectors, err := ds.ListChannelSelectors(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return ResolveChannel(miner, selectors), nil
+}
+
+// TargetVersion returns the agent version the miner should be running, or an
+// empty string when no active rollout covers it.
+func (ds *RolloutDatastore) TargetVersion(ctx context.Context, miner *Miner) (string, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "TargetVersion")
+	defer span.Finish()
+
+	span.SetTag("miner_id", miner.ID)
+
+	channel, err := ds.Channel(ctx, miner)
+	if err != nil {
+		return "", err
+	}
+
+	rollouts, err := ds.ListActive(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	rollout := ResolveRollout(miner, channel, rollouts)
+	if rollout == nil {
+		return "", nil
+	}
+
+	return rollout.TargetVersion, nil
+}
@@ -0,0 +1,31 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+type IncidentCondition string
+
+const (
+	IncidentConditionMissedPings IncidentCondition = "missed_pings"
+	IncidentConditionStuckBusy   IncidentCondition = "stuck_busy"
+	IncidentConditionFlapping    IncidentCondition = "flapping"
+	IncidentConditionTaskLoss    IncidentCondition = "task_loss"
+)
+
+// Incident records a condition detected on a miner and the remediation
+// actions taken for it, comma separated.
+type Incident struct {
+	ID        string            `json:"id"`
+	MinerID   string            `json:"miner_id"`
+	Condition IncidentCondition `json:"condition"`
+	Actions   dbr.NullString    `json:"actions"`
+	Details   dbr.NullString    `json:"details"`
+	CreatedAt *time.Time        `json:"created_at"`
+}
+
+func (Incident) TableName() string {
+	return "miner_incidents"
+}
@@ -0,0 +1,16 @@
+package datastore
+
+import (
+	"time"
+)
+
+// LeaderLease is held by the replica running the singleton background jobs.
+type LeaderLease struct {
+	Name      string
+	Holder    string
+	ExpiresAt *time.Time
+}
+
+func (LeaderLease) TableName() string {
+	return "leader_leases"
+}
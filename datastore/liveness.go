@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Liveness is the hysteresis applied to miner status. An offline miner goes
+// back to Idle after RecoveryPings pings no more than PingGap apart, and a
+// miner switching between online and offline more than MaxTransitions times
+// within Window is unstable.
+type Liveness struct {
+	RecoveryPings  int           `split_words:"true" default:"3"`
+	PingGap        time.Duration `split_words:"true" default:"20s"`
+	Window         time.Duration `default:"10m"`
+	MaxTransitions int           `split_words:"true" default:"6"`
+}
+
+var DefaultLiveness = Liveness{
+	RecoveryPings:  3,
+	PingGap:        time.Second * 20,
+	Window:         time.Minute * 10,
+	MaxTransitions: 6,
+}
+
+// Transition records a transition at now in t and reports whether the miner
+// is unstable with it.
+func (l Liveness) Transition(t Transitions, now time.Time) (Transitions, bool) {
+	t = t.Add(now, l.Window)
+	return t, len(t) > l.MaxTransitions
+}
+
+// Transitions are the times a miner went online or offline.
+type Transitions []time.Time
+
+// Add records a transition and drops the ones older than the window.
+func (t Transitions) Add(now time.Time, window time.Duration) Transitions {
+	return append(t, now).Since(now.Add(-window))
+}
+
+func (t Transitions) Since(since time.Time) Transitions {
+	recent := Transitions{}
+	for _, at := range t {
+		if at.After(since) {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+func (t Transitions) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (t *Transitions) Scan(src interface{}) error {
+	if src == nil {
+		*t = nil
+		return nil
+	}
+
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+
+	return json.Unmarshal(source, t)
+}
@@ -0,0 +1,73 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransitionsAdd(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time {
+		return now.Add(-d)
+	}
+
+	tests := []struct {
+		name string
+		t    Transitions
+		want int
+	}{
+		{"first transition", nil, 1},
+		{"recent ones are kept", Transitions{ago(5 * time.Minute), ago(time.Minute)}, 3},
+		{"old ones are dropped", Transitions{ago(time.Hour), ago(11 * time.Minute), ago(time.Minute)}, 2},
+		{"the window start is excluded", Transitions{ago(10 * time.Minute)}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.t.Add(now, 10*time.Minute)
+			if len(got) != tt.want {
+				t.Fatalf("Add() = %v, want %d transitions", got, tt.want)
+			}
+			if !got[len(got)-1].Equal(now) {
+				t.Errorf("last transition = %s, want %s", got[len(got)-1], now)
+			}
+		})
+	}
+}
+
+func TestLivenessTransition(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	liveness := Liveness{Window: 10 * time.Minute, MaxTransitions: 3}
+
+	recent := func(n int) Transitions {
+		t := Transitions{}
+		for i := n; i > 0; i-- {
+			t = append(t, now.Add(-time.Duration(i)*time.Minute))
+		}
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		t        Transitions
+		want     int
+		unstable bool
+	}{
+		{"first transition", nil, 1, false},
+		{"at the maximum", recent(2), 3, false},
+		{"above the maximum", recent(3), 4, true},
+		{"old ones do not count", append(Transitions{now.Add(-time.Hour), now.Add(-30 * time.Minute)}, recent(2)...), 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unstable := liveness.Transition(tt.t, now)
+			if len(got) != tt.want {
+				t.Errorf("Transition() = %v, want %d transitions", got, tt.want)
+			}
+			if unstable != tt.unstable {
+				t.Errorf("unstable = %v, want %v", unstable, tt.unstable)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+// MaintenanceWindow is a period scheduled by the owner during which the
+// miner stays online but does not receive new tasks.
+type MaintenanceWindow struct {
+	ID        string
+	MinerID   string
+	StartsAt  *time.Time
+	EndsAt    *time.Time
+	CreatedBy dbr.NullString
+	CreatedAt *time.Time
+}
+
+func (w *MaintenanceWindow) IsActive(t time.Time) bool {
+	return w.StartsAt != nil && w.EndsAt != nil && !t.Before(*w.StartsAt) && t.Before(*w.EndsAt)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/mailru/dbr"
 	emitterv1 "github.com/videocoin/cloud-api/emitter/v1"
 	v1 "github.com/videocoin/cloud-api/miners/v1"
+	"github.com/videocoin/cloud-pkg/ethutils"
 )
 
 type Tags map[string]string
@@ -30,6 +31,16 @@ func (t *Tags) Scan(src interface{}) error {
 	return json.Unmarshal(source, t)
 }
 
+// Match reports whether every key of the selector is present with the same value.
+func (t Tags) Match(selector Tags) bool {
+	for k, v := range selector {
+		if t[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
 type Info map[string]interface{}
 
 func (info Info) Value() (driver.Value, error) {
@@ -41,6 +52,10 @@ func (info Info) Value() (driver.Value, error) {
 }
 
 func (info *Info) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+
 	source, ok := src.([]byte)
 	if !ok {
 		return errors.New("type assertion .([]byte) failed")
@@ -66,7 +81,6 @@ type Miner struct {
 	Key                      dbr.NullString
 	Secret                   dbr.NullString
 	IsInternal               bool
-	IsLock                   bool
 	Reward                   float64
 	IsBlock                  bool
 	OrgName                  dbr.NullString
@@ -74,8 +88,179 @@ type Miner struct {
 	OrgDesc                  dbr.NullString
 	AllowThirdpartyDelegates bool
 	DelegatePolicy           dbr.NullString
+	Version                  dbr.NullString
+	ReleaseChannel           dbr.NullString
+	OnlineAt                 *time.Time
+	TasksAssigned            int
+	TasksLost                int
+	ReservedTaskID           dbr.NullString
+	ReservedUntil            *time.Time
+	LeaseHolder              dbr.NullString
+	LeaseAcquiredAt          *time.Time
+	LeaseExpiresAt           *time.Time
+	TaskSlots                int
+	ActiveTasks              int
+	IsDraining               bool
+	DrainRequestedAt         *time.Time
+	Availability             Schedule `sql:"type:json"`
+	VerifiedEncodeCapacity   dbr.NullFloat64
+	VerifiedAt               *time.Time
+	CapacityDiverged         bool
+	ReputationScore          dbr.NullFloat64
+	Reputation               Reputation `sql:"type:json"`
+	QuarantinedUntil         *time.Time
+	ConsecutivePings         int
+	Transitions              Transitions `sql:"type:json"`
+	IsUnstable               bool
 }
 
 func (m *Miner) IsOnline() bool {
 	return m.LastPingAt != nil && m.LastPingAt.After(time.Now().Add(-5*time.Second))
 }
+
+// IsReserved reports whether the miner holds an unexpired reservation.
+func (m *Miner) IsReserved() bool {
+	return m.ReservedUntil != nil && m.ReservedUntil.After(time.Now())
+}
+
+// IsLeased reports whether the internal miner is allocated to a holder and
+// the lease has not expired yet.
+func (m *Miner) IsLeased() bool {
+	return m.LeaseExpiresAt != nil && m.LeaseExpiresAt.After(time.Now())
+}
+
+// IsQuarantined reports whether a remediation policy keeps the miner out of
+// scheduling.
+func (m *Miner) IsQuarantined() bool {
+	return m.QuarantinedUntil != nil && m.QuarantinedUntil.After(time.Now())
+}
+
+// IsScheduledOff reports whether the owner's schedule excludes the current time.
+func (m *Miner) IsScheduledOff() bool {
+	return !m.Availability.IsAvailable(time.Now())
+}
+
+// IsReadyForMaintenance reports whether a draining miner finished its tasks.
+func (m *Miner) IsReadyForMaintenance() bool {
+	return m.IsDraining && m.ActiveTasks == 0
+}
+
+func (m *Miner) TotalStake() float64 {
+	if m.WorkerInfo == nil {
+		return 0
+	}
+	return weiToVID(m.WorkerInfo.TotalStake)
+}
+
+func (m *Miner) SelfStake() float64 {
+	if m.WorkerInfo == nil {
+		return 0
+	}
+	return weiToVID(m.WorkerInfo.SelfStake)
+}
+
+func (m *Miner) DelegatedStake() float64 {
+	if m.WorkerInfo == nil {
+		return 0
+	}
+	return weiToVID(m.WorkerInfo.DelegatedStake)
+}
+
+// Capacity returns the capacity of the given kind. The encode capacity
+// verified by a benchmark takes precedence over the one the agent claims.
+func (m *Miner) Capacity(kind string) float64 {
+	if kind == "encode" && m.IsVerified() {
+		return m.VerifiedEncodeCapacity.Float64
+	}
+	value, _ := m.CapacityInfo[kind].(float64)
+	return value
+}
+
+func (m *Miner) IsVerified() bool {
+	return m.VerifiedAt != nil && m.VerifiedEncodeCapacity.Valid
+}
+
+func (m *Miner) Slots() int {
+	if m.TaskSlots < 1 {
+		return 1
+	}
+	return m.TaskSlots
+}
+
+func (m *Miner) FreeSlots() int {
+	free := m.Slots() - m.ActiveTasks
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+// SlotCapacity returns the capacity of the given kind available to one task.
+func (m *Miner) SlotCapacity(kind string) float64 {
+	return m.Capacity(kind) / float64(m.Slots())
+}
+
+// RemainingCapacity returns the capacity of the given kind left on free slots.
+func (m *Miner) RemainingCapacity(kind string) float64 {
+	return m.SlotCapacity(kind) * float64(m.FreeSlots())
+}
+
+// Geo returns the location resolved from the miner IP address, if any.
+func (m *Miner) Geo() (latitude, longitude float64, country, continent string, ok bool) {
+	geo, isMap := m.SystemInfo["geo"].(map[string]interface{})
+	if !isMap {
+		return 0, 0, "", "", false
+	}
+
+	latitude, ok = geo["latitude"].(float64)
+	if !ok {
+		return 0, 0, "", "", false
+	}
+
+	longitude, ok = geo["longitude"].(float64)
+	if !ok {
+		return 0, 0, "", "", false
+	}
+
+	country, _ = geo["country"].(string)
+	continent, _ = geo["continent"].(string)
+
+	return latitude, longitude, country, continent, true
+}
+
+func weiToVID(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	wei, err := ethutils.ParseBigInt(value)
+	if err != nil {
+		return 0
+	}
+
+	vid, err := ethutils.WeiToEth(&wei)
+	if err != nil {
+		return 0
+	}
+
+	f, _ := vid.Float64()
+	return f
+}
+
+type MinerVersion struct {
+	ID        uint       `json:"-"`
+	MinerID   string     `json:"-"`
+	Version   string     `json:"version"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+type MinerTask struct {
+	MinerID    string
+	TaskID     string
+	Slot       int
+	AssignedAt *time.Time
+}
+
+func (MinerTask) TableName() string {
+	return "miner_tasks"
+}
@@ -0,0 +1,27 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+// Pin forces a task onto a specific miner.
+type Pin struct {
+	ID          string
+	MinerID     string
+	TaskID      string
+	CreatedBy   dbr.NullString
+	Reason      dbr.NullString
+	CreatedAt   *time.Time
+	ExpiresAt   *time.Time
+	CancelledAt *time.Time
+}
+
+func (Pin) TableName() string {
+	return "miner_pins"
+}
+
+func (p *Pin) IsActive() bool {
+	return p.CancelledAt == nil && (p.ExpiresAt == nil || p.ExpiresAt.After(time.Now()))
+}
@@ -0,0 +1,53 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+type ConfigProfile struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	SchemaVersion int        `json:"schema_version"`
+	Revision      int        `json:"revision"`
+	Document      Info       `json:"document" sql:"type:json"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+type ConfigProfileAssignment struct {
+	ID        string         `json:"id"`
+	ProfileID string         `json:"profile_id"`
+	MinerID   dbr.NullString `json:"miner_id"`
+	UserID    dbr.NullString `json:"user_id"`
+	Selector  Tags           `json:"selector" sql:"type:json"`
+	Priority  int            `json:"priority"`
+	CreatedAt *time.Time     `json:"created_at"`
+}
+
+// rank orders assignments by precedence: a miner assignment overrides a tag
+// selector, which overrides a user assignment.
+func (a *ConfigProfileAssignment) rank() int {
+	switch {
+	case a.MinerID.String != "":
+		return 3
+	case len(a.Selector) > 0:
+		return 2
+	case a.UserID.String != "":
+		return 1
+	}
+	return 0
+}
+
+func (a *ConfigProfileAssignment) Matches(miner *Miner) bool {
+	switch {
+	case a.MinerID.String != "":
+		return a.MinerID.String == miner.ID
+	case len(a.Selector) > 0:
+		return miner.Tags.Match(a.Selector)
+	case a.UserID.String != "":
+		return a.UserID.String == miner.UserID
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package datastore
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultReputation is the score of miners without history.
+const DefaultReputation = 0.5
+
+// Reputation is the decaying per-miner reputation and its components, each
+// in the [0, 1] range. The task counters are the miner counters at the last
+// update, so that only new tasks move the task component.
+type Reputation struct {
+	Uptime        float64    `json:"uptime"`
+	TaskSuccess   float64    `json:"task_success"`
+	Verification  float64    `json:"verification"`
+	Conduct       float64    `json:"conduct"`
+	TasksAssigned int        `json:"tasks_assigned"`
+	TasksLost     int        `json:"tasks_lost"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+// ReputationEvents are what happened to the miner since the last update.
+// VerificationFailures are the benchmarks the agent failed, had rejected or
+// left unanswered after delivery.
+type ReputationEvents struct {
+	Online               bool
+	VerificationFailures int
+	Blocks               int
+}
+
+func NewReputation() Reputation {
+	return Reputation{
+		Uptime:       DefaultReputation,
+		TaskSuccess:  DefaultReputation,
+		Verification: DefaultReputation,
+		Conduct:      DefaultReputation,
+	}
+}
+
+func (r Reputation) Score() float64 {
+	if r.UpdatedAt == nil {
+		return DefaultReputation
+	}
+	return (r.Uptime + r.TaskSuccess + r.Verification + r.Conduct) / 4
+}
+
+// Next moves every component towards the observation of the last period. The
+// decay is the weight kept by the past, so older behaviour fades out.
+func (r Reputation) Next(miner *Miner, events ReputationEvents, decay float64) Reputation {
+	if r.UpdatedAt == nil {
+		r = NewReputation()
+		r.TasksAssigned = miner.TasksAssigned
+		r.TasksLost = miner.TasksLost
+	}
+
+	blend := func(prev, now float64) float64 {
+		return decay*prev + (1-decay)*now
+	}
+
+	uptime := 0.0
+	if events.Online {
+		uptime = 1
+	}
+	r.Uptime = blend(r.Uptime, uptime)
+
+	assigned := miner.TasksAssigned - r.TasksAssigned
+	lost := miner.TasksLost - r.TasksLost
+	if assigned > 0 {
+		success := float64(assigned-lost) / float64(assigned)
+		if success < 0 {
+			success = 0
+		}
+		r.TaskSuccess = blend(r.TaskSuccess, success)
+	} else if lost > 0 {
+		r.TaskSuccess = blend(r.TaskSuccess, 0)
+	}
+	r.TasksAssigned = miner.TasksAssigned
+	r.TasksLost = miner.TasksLost
+
+	switch {
+	case events.VerificationFailures > 0 || miner.CapacityDiverged:
+		r.Verification = blend(r.Verification, 0)
+	case miner.IsVerified():
+		r.Verification = blend(r.Verification, 1)
+	}
+
+	conduct := 1.0
+	if events.Blocks > 0 {
+		conduct = 0
+	}
+	r.Conduct = blend(r.Conduct, conduct)
+
+	now := time.Now()
+	r.UpdatedAt = &now
+
+	return r
+}
+
+func (r Reputation) Value() (driver.Value, error) {
+	if r.UpdatedAt == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *Reputation) Scan(src interface{}) error {
+	if src == nil {
+		*r = Reputation{}
+		return nil
+	}
+
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+
+	return json.Unmarshal(source, r)
+}
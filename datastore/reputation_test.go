@@ -0,0 +1,140 @@
+package datastore
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+func TestReputationNext(t *testing.T) {
+	updatedAt := time.Now().Add(-time.Hour)
+	verifiedAt := time.Now()
+
+	tracked := Reputation{
+		Uptime:        0.8,
+		TaskSuccess:   0.8,
+		Verification:  0.8,
+		Conduct:       0.8,
+		TasksAssigned: 10,
+		TasksLost:     2,
+		UpdatedAt:     &updatedAt,
+	}
+
+	tests := []struct {
+		name   string
+		prev   Reputation
+		miner  *Miner
+		events ReputationEvents
+		want   Reputation
+	}{
+		{
+			name:   "first update starts from the default",
+			prev:   Reputation{},
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2},
+			events: ReputationEvents{Online: true},
+			want:   Reputation{Uptime: 0.75, TaskSuccess: 0.5, Verification: 0.5, Conduct: 0.75, TasksAssigned: 10, TasksLost: 2},
+		},
+		{
+			name:   "offline",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2},
+			events: ReputationEvents{},
+			want:   Reputation{Uptime: 0.4, TaskSuccess: 0.8, Verification: 0.8, Conduct: 0.9, TasksAssigned: 10, TasksLost: 2},
+		},
+		{
+			name:   "only new tasks count",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 14, TasksLost: 3},
+			events: ReputationEvents{Online: true},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.775, Verification: 0.8, Conduct: 0.9, TasksAssigned: 14, TasksLost: 3},
+		},
+		{
+			name:   "tasks lost without new ones",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 3},
+			events: ReputationEvents{Online: true},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.4, Verification: 0.8, Conduct: 0.9, TasksAssigned: 10, TasksLost: 3},
+		},
+		{
+			name:   "verified",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2, VerifiedAt: &verifiedAt, VerifiedEncodeCapacity: dbr.NewNullFloat64(100)},
+			events: ReputationEvents{Online: true},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.8, Verification: 0.9, Conduct: 0.9, TasksAssigned: 10, TasksLost: 2},
+		},
+		{
+			name:   "verification failures outweigh a past verification",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2, VerifiedAt: &verifiedAt, VerifiedEncodeCapacity: dbr.NewNullFloat64(100)},
+			events: ReputationEvents{Online: true, VerificationFailures: 1},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.8, Verification: 0.4, Conduct: 0.9, TasksAssigned: 10, TasksLost: 2},
+		},
+		{
+			name:   "diverged capacity",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2, CapacityDiverged: true},
+			events: ReputationEvents{Online: true},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.8, Verification: 0.4, Conduct: 0.9, TasksAssigned: 10, TasksLost: 2},
+		},
+		{
+			name:   "blocked",
+			prev:   tracked,
+			miner:  &Miner{TasksAssigned: 10, TasksLost: 2},
+			events: ReputationEvents{Online: true, Blocks: 1},
+			want:   Reputation{Uptime: 0.9, TaskSuccess: 0.8, Verification: 0.8, Conduct: 0.4, TasksAssigned: 10, TasksLost: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.prev.Next(tt.miner, tt.events, 0.5)
+
+			if got.UpdatedAt == nil {
+				t.Fatal("UpdatedAt is not set")
+			}
+
+			components := []struct {
+				name      string
+				got, want float64
+			}{
+				{"uptime", got.Uptime, tt.want.Uptime},
+				{"task success", got.TaskSuccess, tt.want.TaskSuccess},
+				{"verification", got.Verification, tt.want.Verification},
+				{"conduct", got.Conduct, tt.want.Conduct},
+			}
+			for _, c := range components {
+				if math.Abs(c.got-c.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+				}
+			}
+
+			if got.TasksAssigned != tt.want.TasksAssigned || got.TasksLost != tt.want.TasksLost {
+				t.Errorf("tasks = %d/%d, want %d/%d", got.TasksAssigned, got.TasksLost, tt.want.TasksAssigned, tt.want.TasksLost)
+			}
+		})
+	}
+}
+
+func TestReputationScore(t *testing.T) {
+	updatedAt := time.Now()
+
+	tests := []struct {
+		name string
+		r    Reputation
+		want float64
+	}{
+		{"no history", Reputation{Uptime: 1, TaskSuccess: 1, Verification: 1, Conduct: 1}, DefaultReputation},
+		{"average of the components", Reputation{Uptime: 1, TaskSuccess: 0.5, Verification: 0.25, Conduct: 0.25, UpdatedAt: &updatedAt}, 0.5},
+		{"perfect", Reputation{Uptime: 1, TaskSuccess: 1, Verification: 1, Conduct: 1, UpdatedAt: &updatedAt}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Score(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
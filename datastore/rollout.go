@@ -0,0 +1,91 @@
+package datastore
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/mailru/dbr"
+)
+
+type ReleaseChannel string
+
+const (
+	ReleaseChannelStable ReleaseChannel = "stable"
+	ReleaseChannelBeta   ReleaseChannel = "beta"
+	ReleaseChannelCanary ReleaseChannel = "canary"
+)
+
+func ParseReleaseChannel(s string) (ReleaseChannel, error) {
+	switch c := ReleaseChannel(s); c {
+	case ReleaseChannelStable, ReleaseChannelBeta, ReleaseChannelCanary:
+		return c, nil
+	}
+
+	return "", fmt.Errorf("unknown release channel %q", s)
+}
+
+type ReleaseChannelSelector struct {
+	ID        string         `json:"id"`
+	Channel   ReleaseChannel `json:"channel"`
+	Selector  Tags           `json:"selector" sql:"type:json"`
+	Priority  int            `json:"priority"`
+	CreatedAt *time.Time     `json:"created_at"`
+}
+
+type Rollout struct {
+	ID            string         `json:"id"`
+	Channel       ReleaseChannel `json:"channel"`
+	TargetVersion string         `json:"target_version"`
+	Percentage    int            `json:"percentage"`
+	Hw            dbr.NullString `json:"hw"`
+	IsActive      bool           `json:"is_active"`
+	CreatedAt     *time.Time     `json:"created_at"`
+	UpdatedAt     *time.Time     `json:"updated_at"`
+}
+
+// Includes reports whether the miner falls into the rollout. Miners are
+// bucketed by a stable hash so that raising the percentage only adds miners.
+func (r *Rollout) Includes(miner *Miner) bool {
+	if r.Hw.String != "" && miner.Tags["hw"] != r.Hw.String {
+		return false
+	}
+
+	if r.Percentage >= 100 {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.ID + miner.ID))
+
+	return int(h.Sum32()%100) < r.Percentage
+}
+
+// ResolveChannel returns the release channel of the miner: an explicitly
+// assigned channel wins, then the first matching selector, then stable.
+// Selectors are expected in descending priority order.
+func ResolveChannel(miner *Miner, selectors []*ReleaseChannelSelector) ReleaseChannel {
+	if miner.ReleaseChannel.String != "" {
+		return ReleaseChannel(miner.ReleaseChannel.String)
+	}
+
+	for _, cs := range selectors {
+		if miner.Tags.Match(cs.Selector) {
+			return cs.Channel
+		}
+	}
+
+	return ReleaseChannelStable
+}
+
+// ResolveRollout returns the newest rollout of the channel that includes the
+// miner. Rollouts are expected in descending creation order.
+func ResolveRollout(miner *Miner, channel ReleaseChannel, rollouts []*Rollout) *Rollout {
+	for _, rollout := range rollouts {
+		if rollout.Channel == channel && rollout.Includes(miner) {
+			return rollout
+		}
+	}
+
+	return nil
+}
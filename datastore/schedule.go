@@ -0,0 +1,142 @@
+package datastore
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ScheduleWindow is a daily range of "HH:MM" times on the given days. A window
+// ending at or before its start continues past midnight into the next day.
+type ScheduleWindow struct {
+	Days []string `json:"days"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+}
+
+// Schedule is a weekly availability schedule set by the owner. A miner with
+// an empty schedule is always available.
+type Schedule struct {
+	Timezone string            `json:"timezone"`
+	Windows  []*ScheduleWindow `json:"windows"`
+}
+
+func ParseSchedule(s string) (Schedule, error) {
+	schedule := Schedule{}
+	if err := json.Unmarshal([]byte(s), &schedule); err != nil {
+		return schedule, fmt.Errorf("invalid schedule: %s", err)
+	}
+	return schedule, schedule.Validate()
+}
+
+func (s Schedule) Validate() error {
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid schedule timezone: %s", s.Timezone)
+	}
+	for _, w := range s.Windows {
+		if len(w.Days) == 0 {
+			return errors.New("invalid schedule: window has no days")
+		}
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("invalid schedule day: %s", day)
+			}
+		}
+		if _, err := parseClock(w.From); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsAvailable reports whether t falls inside one of the schedule windows.
+func (s Schedule) IsAvailable(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	day := t.Weekday()
+	prev := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+
+	for _, w := range s.Windows {
+		from, _ := parseClock(w.From)
+		to, _ := parseClock(w.To)
+		overnight := to <= from
+
+		if w.hasDay(day) && minute >= from && (minute < to || overnight) {
+			return true
+		}
+		if overnight && w.hasDay(prev) && minute < to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *ScheduleWindow) hasDay(day time.Weekday) bool {
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock returns the minutes since midnight of a "HH:MM" time, "24:00"
+// included.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid schedule time: %s", s)
+	}
+	return h*60 + m, nil
+}
+
+func (s Schedule) Value() (driver.Value, error) {
+	if len(s.Windows) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *Schedule) Scan(src interface{}) error {
+	if src == nil {
+		*s = Schedule{}
+		return nil
+	}
+
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+
+	return json.Unmarshal(source, s)
+}
@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantErr bool
+	}{
+		{"empty", `{}`, false},
+		{"weekdays", `{"timezone":"Europe/Berlin","windows":[{"days":["mon","Tue"],"from":"09:00","to":"17:30"}]}`, false},
+		{"overnight", `{"windows":[{"days":["fri"],"from":"22:00","to":"06:00"}]}`, false},
+		{"until midnight", `{"windows":[{"days":["sat"],"from":"00:00","to":"24:00"}]}`, false},
+		{"not json", `mon-fri`, true},
+		{"unknown timezone", `{"timezone":"Mars/Olympus"}`, true},
+		{"no days", `{"windows":[{"from":"09:00","to":"17:00"}]}`, true},
+		{"unknown day", `{"windows":[{"days":["monday"],"from":"09:00","to":"17:00"}]}`, true},
+		{"bad time", `{"windows":[{"days":["mon"],"from":"9am","to":"17:00"}]}`, true},
+		{"minutes out of range", `{"windows":[{"days":["mon"],"from":"09:60","to":"17:00"}]}`, true},
+		{"past midnight", `{"windows":[{"days":["mon"],"from":"09:00","to":"24:01"}]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSchedule(tt.s); (err != nil) != tt.wantErr {
+				t.Errorf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleIsAvailable(t *testing.T) {
+	office := Schedule{
+		Timezone: "Europe/Berlin",
+		Windows:  []*ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "09:00", To: "17:00"}},
+	}
+	nights := Schedule{
+		Windows: []*ScheduleWindow{{Days: []string{"fri"}, From: "22:00", To: "06:00"}},
+	}
+	allDay := Schedule{
+		Windows: []*ScheduleWindow{{Days: []string{"sun"}, From: "00:00", To: "24:00"}},
+	}
+
+	// 2020-06-01 is a Monday, Berlin is at UTC+2 in summer.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		t        time.Time
+		want     bool
+	}{
+		{"empty schedule", Schedule{}, at(1, 3, 0), true},
+		{"inside in the local timezone", office, at(1, 7, 0), true},
+		{"before in the local timezone", office, at(1, 6, 59), false},
+		{"end is exclusive", office, at(1, 15, 0), false},
+		{"weekend", office, at(6, 10, 0), false},
+		{"overnight on the day", nights, at(5, 23, 0), true},
+		{"overnight on the next day", nights, at(6, 5, 59), true},
+		{"overnight end is exclusive", nights, at(6, 6, 0), false},
+		{"overnight before the start", nights, at(5, 21, 59), false},
+		{"overnight on the previous day", nights, at(5, 5, 0), false},
+		{"whole day", allDay, at(7, 23, 59), true},
+		{"whole day does not spill over", allDay, at(8, 0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.IsAvailable(tt.t); got != tt.want {
+				t.Errorf("IsAvailable(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
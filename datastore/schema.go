@@ -0,0 +1,72 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Ping checks that the database accepts connections.
+func (ds *Datastore) Ping(ctx context.Context) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "Ping")
+	defer span.Finish()
+
+	if err := ds.db.DB().PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %s", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the latest migration goose has applied, read from
+// its version table.
+func (ds *Datastore) SchemaVersion(ctx context.Context, table string) (int64, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "SchemaVersion")
+	defer span.Finish()
+
+	span.SetTag("table", table)
+
+	var version int64
+	row := ds.db.DB().QueryRowContext(
+		ctx,
+		fmt.Sprintf("SELECT IFNULL(MAX(version_id), 0) FROM `%s` WHERE is_applied = 1", table),
+	)
+	if err := row.Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %s", err)
+	}
+
+	return version, nil
+}
+
+// LatestMigration returns the highest version among the goose migrations in
+// dir, which is the schema version the binary expects.
+func LatestMigration(dir string) (int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %s", err)
+	}
+
+	var latest int64
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".sql" {
+			continue
+		}
+
+		parts := strings.SplitN(f.Name(), "_", 2)
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
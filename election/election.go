@@ -0,0 +1,130 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+// Elector keeps one replica as the leader through a lease row renewed every
+// third of its ttl. When the leader stops renewing, another replica takes the
+// lease over once it expires.
+type Elector struct {
+	logger *logrus.Entry
+	ds     *datastore.Datastore
+	name   string
+	holder string
+	ttl    time.Duration
+	ticker *time.Ticker
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex    sync.RWMutex
+	isLeader bool
+}
+
+func NewElector(opts ...Option) (*Elector, error) {
+	hostname, _ := os.Hostname()
+
+	e := &Elector{
+		name:   "miners",
+		holder: fmt.Sprintf("%s-%s", hostname, uuid.New().String()),
+		ttl:    time.Second * 30,
+	}
+	for _, o := range opts {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+
+	e.ticker = time.NewTicker(e.ttl / 3)
+
+	return e, nil
+}
+
+func (e *Elector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	e.campaign(ctx)
+	go func() {
+		defer close(e.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the campaign and releases the lease so another replica takes
+// over without waiting for it to expire.
+func (e *Elector) Stop(ctx context.Context) error {
+	e.ticker.Stop()
+	if e.cancel != nil {
+		e.cancel()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if e.IsLeader() {
+		e.setLeader(false)
+		if err := e.ds.Leader.Release(ctx, e.name, e.holder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	isLeader, err := e.ds.Leader.Acquire(ctx, e.name, e.holder, e.ttl)
+	if err != nil {
+		// Without knowing whether the lease was renewed, step down rather
+		// than risk two leaders.
+		e.logger.WithError(err).Error("failed to acquire leadership")
+		isLeader = false
+	}
+
+	e.setLeader(isLeader)
+}
+
+func (e *Elector) setLeader(isLeader bool) {
+	e.mutex.Lock()
+	changed := e.isLeader != isLeader
+	e.isLeader = isLeader
+	e.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if isLeader {
+		e.logger.WithField("holder", e.holder).Info("acquired leadership")
+	} else {
+		e.logger.WithField("holder", e.holder).Info("lost leadership")
+	}
+}
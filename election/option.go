@@ -0,0 +1,38 @@
+package election
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+type Option func(*Elector) error
+
+func WithLogger(logger *logrus.Entry) Option {
+	return func(e *Elector) error {
+		e.logger = logger
+		return nil
+	}
+}
+
+func WithDatastore(ds *datastore.Datastore) Option {
+	return func(e *Elector) error {
+		e.ds = ds
+		return nil
+	}
+}
+
+func WithName(name string) Option {
+	return func(e *Elector) error {
+		e.name = name
+		return nil
+	}
+}
+
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Elector) error {
+		e.ttl = ttl
+		return nil
+	}
+}
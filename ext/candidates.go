@@ -0,0 +1,104 @@
+package ext
+
+import (
+	"time"
+)
+
+// KeyCandidates extends GetMinersCandidates with a CandidatesRequest and
+// answers with a CandidatesResponse.
+const KeyCandidates = "x-miners-candidates-bin"
+
+const (
+	AntiAffinityScopeMiner = "miner"
+	AntiAffinityScopeUser  = "user"
+)
+
+// CandidatesRequest refines the capacity thresholds of
+// MinersCandidatesRequest. Limit caps the number of candidates returned,
+// the service default applies when it is zero. Origin, when set, restricts
+// and ranks candidates by location; distances are in kilometers.
+//
+// With Reserve the best candidate is reserved for the task and returned
+// alone, so concurrent callers never get the same miner. The reservation
+// turns into the assignment on AssignTask and is released by UnassignTask of
+// the task or when it expires.
+type CandidatesRequest struct {
+	Limit        int           `json:"limit,omitempty"`
+	Origin       *Origin       `json:"origin,omitempty"`
+	MaxDistance  float64       `json:"max_distance,omitempty"`
+	PreferRadius float64       `json:"prefer_radius,omitempty"`
+	Affinity     *Affinity     `json:"affinity,omitempty"`
+	Requirements *Requirements `json:"requirements,omitempty"`
+	Reserve      *Reservation  `json:"reserve,omitempty"`
+}
+
+// Reservation asks to reserve the candidate for the task. TTLSeconds
+// overrides the service default.
+type Reservation struct {
+	TaskID     string `json:"task_id"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+}
+
+// CandidatesResponse holds the score of every returned candidate, in the
+// order of MinersCandidatesResponse.Items.
+type CandidatesResponse struct {
+	Items []*CandidateScore `json:"items"`
+}
+
+// CandidateScore is the weighted score of a candidate and the [0, 1] score
+// of every factor it is made of.
+type CandidateScore struct {
+	ID            string             `json:"id"`
+	Score         float64            `json:"score"`
+	Factors       map[string]float64 `json:"factors"`
+	ReservedUntil *time.Time         `json:"reserved_until,omitempty"`
+}
+
+// Origin is the ingest point a stream should be transcoded close to. Either
+// coordinates, a country ISO code, a continent code or a combination of them
+// can be given.
+type Origin struct {
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Country   string   `json:"country,omitempty"`
+	Continent string   `json:"continent,omitempty"`
+}
+
+// Coords returns the coordinates of the origin, if both are given.
+func (o *Origin) Coords() (latitude, longitude float64, ok bool) {
+	if o == nil || o.Latitude == nil || o.Longitude == nil {
+		return 0, 0, false
+	}
+	return *o.Latitude, *o.Longitude, true
+}
+
+// Affinity restricts and ranks candidates by their tags and owners.
+type Affinity struct {
+	RequiredTags  map[string]string `json:"required_tags,omitempty"`
+	PreferredTags map[string]string `json:"preferred_tags,omitempty"`
+	ExcludeUsers  []string          `json:"exclude_users,omitempty"`
+	ExcludeMiners []string          `json:"exclude_miners,omitempty"`
+	InternalOnly  bool              `json:"internal_only,omitempty"`
+	AntiAffinity  *AntiAffinity     `json:"anti_affinity,omitempty"`
+}
+
+// AntiAffinity keeps a task away from the miners, or from the hardware of
+// the owners, already running or reserved for any of the given tasks, e.g.
+// the other renditions of the same stream.
+type AntiAffinity struct {
+	Tasks []string `json:"tasks"`
+	Scope string   `json:"scope,omitempty"`
+}
+
+// Requirements describe the hardware and codec a task needs. FPS is the
+// frame rate a single task must sustain and is checked per task slot.
+type Requirements struct {
+	Codec       string  `json:"codec,omitempty"`
+	Profile     string  `json:"profile,omitempty"`
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	FPS         float64 `json:"fps,omitempty"`
+	HWEncoder   string  `json:"hw_encoder,omitempty"`
+	Arch        string  `json:"arch,omitempty"`
+	MinMemoryMB float64 `json:"min_memory_mb,omitempty"`
+}
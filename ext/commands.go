@@ -0,0 +1,41 @@
+package ext
+
+import (
+	"time"
+)
+
+// TagCommand is written by owners with SetTags to enqueue a CommandRequest
+// on their miner. TagCommands is the JSON list of the latest commands of the
+// miner, newest first, returned to the owner only.
+const (
+	TagCommand  = "command"
+	TagCommands = "commands"
+)
+
+// CommandResult is the outcome of a delivered command. Benchmark results
+// are reported with the capacity info of the ping instead, only their
+// failures are reported here.
+type CommandResult struct {
+	ID        string                 `json:"id"`
+	Succeeded bool                   `json:"succeeded"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+}
+
+// CommandRequest enqueues a command. TTLSeconds defaults to an hour.
+type CommandRequest struct {
+	Type       string                 `json:"type"`
+	Payload    map[string]interface{} `json:"payload,omitempty"`
+	TTLSeconds int                    `json:"ttl_seconds,omitempty"`
+}
+
+type Command struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Payload     map[string]interface{} `json:"payload,omitempty"`
+	Status      string                 `json:"status,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	CreatedAt   *time.Time             `json:"created_at,omitempty"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
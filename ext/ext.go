@@ -0,0 +1,83 @@
+// Package ext is the contract this service adds on top of the miners/v1 API
+// of cloud-api, whose messages it cannot change. Features the messages have
+// no fields for are carried in one of four ways, depending on what they are.
+//
+// Miner attributes are tags. MinerResponse.Tags carries the extra read-only
+// state of a miner and owners change settings of their own miners with the
+// reserved keys of SetTagsRequest.Tags. The keys are the Tag constants of
+// this package.
+//
+// Call parameters and results are gRPC metadata. Every extended RPC has one
+// binary metadata key holding a JSON document: the caller sends the request
+// document in the request metadata and the service answers with the
+// response document in the response header under the same key. Calls
+// without the key behave as before. The keys and documents are the
+// Key constants and types of this package, with helpers for both sides.
+//
+// Operations reserved to ops are the admin HTTP API, served under /admin on
+// the metrics address. It takes the same bearer token as the gRPC API and
+// only accepts the users listed in ADMIN_USER_IDS.
+//
+// Calls miners/v1 has no RPC for are a separate gRPC service served next to
+// it on the same address, with JSON messages: the session stream of
+// SessionServiceName. On it agents send heartbeats, telemetry and command
+// results, and the service pushes commands, acknowledgements, task
+// assignments, drain requests, target versions and profile changes. The
+// miner stays online while the stream is open. Agents without it keep
+// polling Ping with KeyPing.
+package ext
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// NewOutgoingContext returns the context of a call carrying v under key.
+func NewOutgoingContext(ctx context.Context, key string, v interface{}) (context.Context, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to marshal %s: %s", key, err)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, key, string(b)), nil
+}
+
+// FromIncomingContext decodes the document sent under key into v and reports
+// whether there was one.
+func FromIncomingContext(ctx context.Context, key string, v interface{}) (bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	return FromMD(md, key, v)
+}
+
+// SetHeader sends v under key in the response header of the call.
+func SetHeader(ctx context.Context, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %s", key, err)
+	}
+
+	return grpc.SetHeader(ctx, metadata.Pairs(key, string(b)))
+}
+
+// FromMD decodes the document under key of metadata received with
+// grpc.Header into v and reports whether there was one.
+func FromMD(md metadata.MD, key string, v interface{}) (bool, error) {
+	values := md.Get(key)
+	if len(values) == 0 || values[0] == "" {
+		return false, nil
+	}
+
+	if err := json.Unmarshal([]byte(values[0]), v); err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, err)
+	}
+
+	return true, nil
+}
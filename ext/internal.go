@@ -0,0 +1,31 @@
+package ext
+
+import (
+	"time"
+)
+
+// KeyInternalMiner extends GetInternalMiner with an InternalMinerRequest and
+// answers with an InternalMinerResponse. Without it the call leases a miner
+// to the caller host for the service default TTL.
+const KeyInternalMiner = "x-miners-internal-bin"
+
+const (
+	LeaseActionRenew   = "renew"
+	LeaseActionRelease = "release"
+)
+
+// InternalMinerRequest leases a miner to Holder for TTLSeconds. With an
+// Action, the lease Holder has on MinerID is renewed or released instead.
+// Holder defaults to the caller host; allocators running several replicas
+// should set it so that any replica can renew or release the lease.
+type InternalMinerRequest struct {
+	Holder     string `json:"holder,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+	MinerID    string `json:"miner_id,omitempty"`
+	Action     string `json:"action,omitempty"`
+}
+
+type InternalMinerResponse struct {
+	Holder         string     `json:"holder"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+}
@@ -0,0 +1,35 @@
+package ext
+
+import (
+	"time"
+)
+
+// TagDrain is set by owners to "true" to stop new tasks from reaching their
+// miner and to "false" to resume. Responses carry "draining" while tasks
+// are left and "drained" once the miner is ready for maintenance.
+//
+// TagMaintenance is set by owners to a MaintenanceRequest. Owners get the
+// JSON list of the MaintenanceWindow that have not ended with their miner.
+const (
+	TagDrain       = "drain"
+	TagMaintenance = "maintenance"
+)
+
+const (
+	DrainDraining = "draining"
+	DrainDrained  = "drained"
+)
+
+// MaintenanceRequest schedules a window from StartsAt to EndsAt, or deletes
+// the window of ID Delete.
+type MaintenanceRequest struct {
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Delete   string     `json:"delete,omitempty"`
+}
+
+type MaintenanceWindow struct {
+	ID       string     `json:"id"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
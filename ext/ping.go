@@ -0,0 +1,33 @@
+package ext
+
+// KeyPing extends Ping with a PingRequest and answers with a PingResponse.
+// Agents that send it, even empty, are kept up to date: the pending commands
+// of the miner are delivered in the response, the agent reports their
+// results on a later ping, and the response advertises the agent version
+// the miner should run whenever it differs from the registered one and the
+// effective configuration profile whenever it differs from the one the agent
+// reports.
+const KeyPing = "x-miners-ping-bin"
+
+// PingRequest reports the command results and the name and revision of the
+// configuration profile the agent runs with.
+type PingRequest struct {
+	Results        []*CommandResult `json:"results,omitempty"`
+	ConfigProfile  string           `json:"config_profile,omitempty"`
+	ConfigRevision int              `json:"config_revision,omitempty"`
+}
+
+type PingResponse struct {
+	Commands      []*Command     `json:"commands"`
+	TargetVersion string         `json:"target_version,omitempty"`
+	ConfigProfile *ConfigProfile `json:"config_profile,omitempty"`
+}
+
+// ConfigProfile is the configuration profile of a miner, also returned on
+// registration under TagConfigProfile.
+type ConfigProfile struct {
+	Name          string                 `json:"name"`
+	SchemaVersion int                    `json:"schema_version"`
+	Revision      int                    `json:"revision"`
+	Document      map[string]interface{} `json:"document"`
+}
@@ -0,0 +1,87 @@
+package ext
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// SessionServiceName is the gRPC service of the session stream, served next
+// to miners/v1 on the same address. Its single bidirectional streaming
+// method SessionMethod exchanges AgentMessage and SessionMessage documents
+// encoded with the Codec of this package, selected on the client with
+// grpc.CallContentSubtype(CodecName).
+const (
+	SessionServiceName = "cloud.miners.ext.SessionService"
+	SessionMethod      = "/" + SessionServiceName + "/Session"
+)
+
+// AgentMessage is sent by the agent on the session stream. The first one
+// must carry Hello, the following ones heartbeats with telemetry and the
+// results of the commands pushed to the agent.
+type AgentMessage struct {
+	Hello     *SessionHello    `json:"hello,omitempty"`
+	Heartbeat *Heartbeat       `json:"heartbeat,omitempty"`
+	Results   []*CommandResult `json:"results,omitempty"`
+}
+
+// SessionHello opens the session of a registered miner with the name and
+// revision of the configuration profile the agent runs with.
+type SessionHello struct {
+	ClientID       string `json:"client_id"`
+	ConfigProfile  string `json:"config_profile,omitempty"`
+	ConfigRevision int    `json:"config_revision,omitempty"`
+}
+
+// Heartbeat carries the same system and capacity info as PingRequest.
+type Heartbeat struct {
+	SystemInfo   json.RawMessage `json:"system_info,omitempty"`
+	CapacityInfo json.RawMessage `json:"capacity_info,omitempty"`
+}
+
+// SessionMessage is pushed by the service on the session stream whenever
+// there is something new for the agent: the commands to run and the ids of
+// the command results it stored. Task is the task the miner should run, an
+// empty ID meaning none, Drain asks the agent to stop or resume taking
+// tasks, and TargetVersion and ConfigProfile are as in PingResponse. These
+// are sent on the first message and then only when they change.
+type SessionMessage struct {
+	Commands      []*Command     `json:"commands,omitempty"`
+	Acks          []string       `json:"acks,omitempty"`
+	Task          *SessionTask   `json:"task,omitempty"`
+	Drain         *bool          `json:"drain,omitempty"`
+	TargetVersion string         `json:"target_version,omitempty"`
+	ConfigProfile *ConfigProfile `json:"config_profile,omitempty"`
+}
+
+type SessionTask struct {
+	ID string `json:"id"`
+}
+
+// IsEmpty reports whether the message has nothing for the agent.
+func (m *SessionMessage) IsEmpty() bool {
+	return len(m.Commands) == 0 && len(m.Acks) == 0 && m.Task == nil && m.Drain == nil &&
+		m.TargetVersion == "" && m.ConfigProfile == nil
+}
+
+// CodecName is the content subtype of the session stream.
+const CodecName = "json"
+
+// Codec encodes the session stream messages as JSON.
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (Codec) Name() string {
+	return CodecName
+}
+
+func init() {
+	encoding.RegisterCodec(Codec{})
+}
@@ -0,0 +1,42 @@
+package ext
+
+// Tags reserved by the service. Owners set the writable ones with SetTags,
+// the others are only returned on MinerResponse.Tags.
+const (
+	// TagHardware is the hardware class reported on registration.
+	TagHardware = "hw"
+	// TagForceTaskID pins a task to the miner. Kept for older clients.
+	TagForceTaskID = "force_task_id"
+	// TagAvailability is the owner's weekly schedule when set and
+	// "scheduled_off" on responses while the schedule excludes now.
+	TagAvailability = "availability"
+	// TagStability is "unstable" while flapping keeps the miner out of
+	// scheduling.
+	TagStability = "stability"
+	// TagReputation is the reputation score in [0, 1].
+	TagReputation = "reputation"
+	// TagReputationComponents is the JSON breakdown of the reputation,
+	// returned to the owner only.
+	TagReputationComponents = "reputation_components"
+	// TagIncidents is the JSON list of the latest remediation incidents,
+	// newest first, returned to the owner only.
+	TagIncidents = "incidents"
+	// TagVersion is the agent version the miner registered with.
+	TagVersion = "version"
+	// TagVersionHistory is the JSON list of the agent versions the miner
+	// ran, newest first, returned to the owner only.
+	TagVersionHistory = "version_history"
+	// TagTargetVersion is the agent version the miner should run, returned
+	// on registration.
+	TagTargetVersion = "target_version"
+	// TagConfigProfile is the JSON effective configuration profile, returned
+	// on registration.
+	TagConfigProfile = "config_profile"
+	// TagEligibilityPolicy is the JSON eligibility policy, returned on
+	// registration.
+	TagEligibilityPolicy = "eligibility_policy"
+)
+
+// AvailabilityScheduledOff is the TagAvailability value of miners outside of
+// their schedule.
+const AvailabilityScheduledOff = "scheduled_off"
@@ -0,0 +1,254 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/election"
+	grpchealth "google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Kind tells which probe a check belongs to. Liveness checks detect a process
+// that needs a restart, readiness checks a process that should not take
+// traffic. Readiness covers liveness checks as well.
+type Kind string
+
+const (
+	KindLiveness  Kind = "liveness"
+	KindReadiness Kind = "readiness"
+)
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFailed   Status = "failed"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+// Check is a single dependency check. A failing critical check fails its
+// probe, any other failing check only degrades it.
+type Check struct {
+	Name     string
+	Kind     Kind
+	Critical bool
+	Func     CheckFunc
+}
+
+type Result struct {
+	Name      string    `json:"name"`
+	Kind      Kind      `json:"kind"`
+	Critical  bool      `json:"critical"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type Report struct {
+	Status Status    `json:"status"`
+	Leader bool      `json:"leader"`
+	Checks []*Result `json:"checks"`
+}
+
+// Checker runs the checks periodically, keeps their latest results for the
+// HTTP probes and mirrors them into the gRPC health service: every
+// registered gRPC service is SERVING while the probe of its kind passes.
+type Checker struct {
+	logger   *logrus.Entry
+	checks   []*Check
+	interval time.Duration
+	timeout  time.Duration
+	elector  *election.Elector
+	grpc     *grpchealth.Server
+	services map[string]Kind
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mutex    sync.RWMutex
+	results  map[string]*Result
+	shutdown bool
+}
+
+func NewChecker(opts ...Option) (*Checker, error) {
+	c := &Checker{
+		logger:   logrus.NewEntry(logrus.New()),
+		interval: time.Second * 10,
+		timeout:  time.Second * 5,
+		services: map[string]Kind{},
+		results:  map[string]*Result{},
+	}
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Start runs the first round of checks before returning so the probes never
+// report on checks that have not run yet.
+func (c *Checker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	c.run(ctx)
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.run(ctx)
+			}
+		}
+	}()
+}
+
+func (c *Checker) Stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+
+	c.cancel()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown fails readiness from now on so that traffic moves away while the
+// service drains. Liveness is not affected.
+func (c *Checker) Shutdown() {
+	c.mutex.Lock()
+	c.shutdown = true
+	c.mutex.Unlock()
+}
+
+// Liveness reports on the liveness checks only.
+func (c *Checker) Liveness() *Report {
+	return c.report(KindLiveness)
+}
+
+// Readiness reports on all checks.
+func (c *Checker) Readiness() *Report {
+	return c.report(KindReadiness)
+}
+
+func (c *Checker) run(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check *Check) {
+			defer wg.Done()
+
+			result := c.check(ctx, check)
+			if result.Status != StatusOK {
+				c.logger.WithField("check", check.Name).Warningf("health check %s: %s", result.Status, result.Error)
+			}
+
+			c.mutex.Lock()
+			c.results[check.Name] = result
+			c.mutex.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil || c.grpc == nil {
+		return
+	}
+
+	for service, kind := range c.services {
+		status := healthv1.HealthCheckResponse_SERVING
+		if c.report(kind).Status == StatusFailed {
+			status = healthv1.HealthCheckResponse_NOT_SERVING
+		}
+		c.grpc.SetServingStatus(service, status)
+	}
+}
+
+func (c *Checker) check(ctx context.Context, check *Check) *Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result := &Result{
+		Name:      check.Name,
+		Kind:      check.Kind,
+		Critical:  check.Critical,
+		Status:    StatusOK,
+		CheckedAt: time.Now(),
+	}
+
+	err := check.Func(ctx)
+	result.Duration = time.Since(result.CheckedAt).String()
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusDegraded
+		if check.Critical {
+			result.Status = StatusFailed
+		}
+	}
+
+	return result
+}
+
+func (c *Checker) report(kind Kind) *Report {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	report := &Report{
+		Status: StatusOK,
+		Leader: c.elector == nil || c.elector.IsLeader(),
+		Checks: []*Result{},
+	}
+
+	for _, check := range c.checks {
+		if kind == KindLiveness && check.Kind != KindLiveness {
+			continue
+		}
+
+		result, ok := c.results[check.Name]
+		if !ok {
+			continue
+		}
+
+		report.Checks = append(report.Checks, result)
+
+		switch {
+		case result.Status == StatusFailed:
+			report.Status = StatusFailed
+		case result.Status == StatusDegraded && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+
+	if c.shutdown && kind == KindReadiness {
+		report.Status = StatusFailed
+		report.Checks = append(report.Checks, &Result{
+			Name:      "shutdown",
+			Kind:      KindReadiness,
+			Critical:  true,
+			Status:    StatusFailed,
+			Error:     "service is shutting down",
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		})
+	}
+
+	return report
+}
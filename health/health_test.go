@@ -0,0 +1,190 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	grpchealth "google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func check(name string, kind Kind, critical bool, err error) *Check {
+	return &Check{
+		Name:     name,
+		Kind:     kind,
+		Critical: critical,
+		Func: func(ctx context.Context) error {
+			return err
+		},
+	}
+}
+
+func TestReport(t *testing.T) {
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name           string
+		checks         []*Check
+		liveness       Status
+		livenessCount  int
+		readiness      Status
+		readinessCount int
+	}{
+		{
+			name:           "no checks",
+			liveness:       StatusOK,
+			readiness:      StatusOK,
+			livenessCount:  0,
+			readinessCount: 0,
+		},
+		{
+			name: "all passing",
+			checks: []*Check{
+				check("loop", KindLiveness, true, nil),
+				check("mysql", KindReadiness, true, nil),
+			},
+			liveness:       StatusOK,
+			livenessCount:  1,
+			readiness:      StatusOK,
+			readinessCount: 2,
+		},
+		{
+			name: "non critical failure degrades",
+			checks: []*Check{
+				check("loop", KindLiveness, true, nil),
+				check("emitter", KindReadiness, false, failure),
+			},
+			liveness:       StatusOK,
+			livenessCount:  1,
+			readiness:      StatusDegraded,
+			readinessCount: 2,
+		},
+		{
+			name: "critical failure fails readiness only",
+			checks: []*Check{
+				check("loop", KindLiveness, true, nil),
+				check("emitter", KindReadiness, false, failure),
+				check("mysql", KindReadiness, true, failure),
+			},
+			liveness:       StatusOK,
+			livenessCount:  1,
+			readiness:      StatusFailed,
+			readinessCount: 3,
+		},
+		{
+			name: "liveness failure fails readiness as well",
+			checks: []*Check{
+				check("loop", KindLiveness, true, failure),
+				check("mysql", KindReadiness, true, nil),
+			},
+			liveness:       StatusFailed,
+			livenessCount:  1,
+			readiness:      StatusFailed,
+			readinessCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{}
+			for _, c := range tt.checks {
+				opts = append(opts, WithCheck(c))
+			}
+
+			c, err := NewChecker(opts...)
+			if err != nil {
+				t.Fatalf("failed to create checker: %s", err)
+			}
+
+			c.run(context.Background())
+
+			liveness := c.Liveness()
+			if liveness.Status != tt.liveness || len(liveness.Checks) != tt.livenessCount {
+				t.Errorf("Liveness() = %s with %d checks, want %s with %d", liveness.Status, len(liveness.Checks), tt.liveness, tt.livenessCount)
+			}
+
+			readiness := c.Readiness()
+			if readiness.Status != tt.readiness || len(readiness.Checks) != tt.readinessCount {
+				t.Errorf("Readiness() = %s with %d checks, want %s with %d", readiness.Status, len(readiness.Checks), tt.readiness, tt.readinessCount)
+			}
+
+			if !readiness.Leader {
+				t.Error("Readiness() leader = false without an elector")
+			}
+
+			for _, r := range readiness.Checks {
+				if (r.Status == StatusOK) != (r.Error == "") {
+					t.Errorf("check %s: status %s with error %q", r.Name, r.Status, r.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCServingStatus(t *testing.T) {
+	server := grpchealth.NewServer()
+
+	c, err := NewChecker(
+		WithCheck(check("loop", KindLiveness, true, nil)),
+		WithCheck(check("mysql", KindReadiness, true, errors.New("connection refused"))),
+		WithGRPCHealth(server),
+		WithService("live", KindLiveness),
+		WithService("ready", KindReadiness),
+	)
+	if err != nil {
+		t.Fatalf("failed to create checker: %s", err)
+	}
+
+	c.run(context.Background())
+
+	tests := []struct {
+		service string
+		want    healthv1.HealthCheckResponse_ServingStatus
+	}{
+		{"live", healthv1.HealthCheckResponse_SERVING},
+		{"ready", healthv1.HealthCheckResponse_NOT_SERVING},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			resp, err := server.Check(context.Background(), &healthv1.HealthCheckRequest{Service: tt.service})
+			if err != nil {
+				t.Fatalf("Check() error = %s", err)
+			}
+			if resp.Status != tt.want {
+				t.Errorf("Check() = %s, want %s", resp.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c, err := NewChecker(
+		WithCheck(check("loop", KindLiveness, true, nil)),
+		WithCheck(check("mysql", KindReadiness, true, nil)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create checker: %s", err)
+	}
+
+	c.run(context.Background())
+	c.Shutdown()
+
+	tests := []struct {
+		name   string
+		report *Report
+		want   Status
+	}{
+		{"liveness", c.Liveness(), StatusOK},
+		{"readiness", c.Readiness(), StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.report.Status != tt.want {
+				t.Errorf("status = %s, want %s", tt.report.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/election"
+	grpchealth "google.golang.org/grpc/health"
+)
+
+type Option func(*Checker) error
+
+func WithLogger(logger *logrus.Entry) Option {
+	return func(c *Checker) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checker) error {
+		c.interval = interval
+		return nil
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) error {
+		c.timeout = timeout
+		return nil
+	}
+}
+
+func WithElector(elector *election.Elector) Option {
+	return func(c *Checker) error {
+		c.elector = elector
+		return nil
+	}
+}
+
+func WithCheck(check *Check) Option {
+	return func(c *Checker) error {
+		c.checks = append(c.checks, check)
+		return nil
+	}
+}
+
+// WithGRPCHealth drives the serving status of the gRPC health service.
+func WithGRPCHealth(server *grpchealth.Server) Option {
+	return func(c *Checker) error {
+		c.grpc = server
+		return nil
+	}
+}
+
+// WithService reports the gRPC service as SERVING while the probe of the
+// given kind passes.
+func WithService(service string, kind Kind) Option {
+	return func(c *Checker) error {
+		c.services[service] = kind
+		return nil
+	}
+}
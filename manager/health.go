@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// heartbeatTolerance is how many intervals a job may miss before it is
+// reported as stuck.
+const heartbeatTolerance = 3
+
+type heartbeat struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// Heartbeat is the last tick a background job has handled.
+type Heartbeat struct {
+	Job      string        `json:"job"`
+	Interval time.Duration `json:"interval"`
+	Last     time.Time     `json:"last"`
+	Stale    bool          `json:"stale"`
+}
+
+func (m *Manager) newJobTicker(job string, interval time.Duration) *time.Ticker {
+	m.heartbeats[job] = &heartbeat{interval: interval}
+	return time.NewTicker(interval)
+}
+
+func (m *Manager) resetHeartbeats() {
+	m.hbMutex.Lock()
+	defer m.hbMutex.Unlock()
+
+	now := time.Now()
+	for _, hb := range m.heartbeats {
+		hb.last = now
+	}
+}
+
+// beat records that a job has picked up a tick or made progress through a
+// long one. Followers beat too, so a stuck loop is noticed on every replica
+// and not only on the leader.
+func (m *Manager) beat(job string) {
+	m.hbMutex.Lock()
+	defer m.hbMutex.Unlock()
+
+	if hb, ok := m.heartbeats[job]; ok {
+		hb.last = time.Now()
+	}
+}
+
+// Heartbeats returns the heartbeat of every background job sorted by name.
+func (m *Manager) Heartbeats() []*Heartbeat {
+	m.hbMutex.RLock()
+	defer m.hbMutex.RUnlock()
+
+	items := make([]*Heartbeat, 0, len(m.heartbeats))
+	for job, hb := range m.heartbeats {
+		items = append(items, &Heartbeat{
+			Job:      job,
+			Interval: hb.interval,
+			Last:     hb.last,
+			Stale:    !hb.last.IsZero() && time.Since(hb.last) > hb.interval*heartbeatTolerance,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Job < items[j].Job })
+
+	return items
+}
+
+// CheckHeartbeats fails when a background job has not picked up a tick for
+// several of its intervals.
+func (m *Manager) CheckHeartbeats() error {
+	stale := []string{}
+	for _, hb := range m.Heartbeats() {
+		if hb.Stale {
+			stale = append(stale, fmt.Sprintf("%s (last %s ago)", hb.Job, time.Since(hb.Last).Round(time.Second)))
+		}
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf("stale jobs: %s", strings.Join(stale, ", "))
+	}
+
+	return nil
+}
+
+// CheckEmitter fails when the connection to the emitter service is broken.
+func (m *Manager) CheckEmitter() error {
+	if m.emitterConn == nil {
+		return errors.New("emitter client is not configured")
+	}
+
+	switch state := m.emitterConn.GetState(); state {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("emitter connection is %s", strings.ToLower(state.String()))
+	}
+
+	return nil
+}
@@ -2,33 +2,79 @@ package manager
 
 import (
 	"context"
+	"fmt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	emitterv1 "github.com/videocoin/cloud-api/emitter/v1"
+	v1 "github.com/videocoin/cloud-api/miners/v1"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/election"
+	"google.golang.org/grpc"
 )
 
+// emitterCallTimeout bounds every emitter call of the worker loops, so that
+// a slow emitter delays a loop without stalling its heartbeat.
+const emitterCallTimeout = time.Second * 5
+
 type Manager struct {
 	logger         *logrus.Entry
 	offlineTimeout time.Duration
 	offlineTicker  *time.Ticker
+	stuckTicker    *time.Ticker
 	wiTicker       *time.Ticker
 	wrTicker       *time.Ticker
+	cmdTicker      *time.Ticker
+	resTicker      *time.Ticker
+	leaseTicker    *time.Ticker
+	blockTicker    *time.Ticker
+	benchTicker    *time.Ticker
+	repTicker      *time.Ticker
 	ds             *datastore.Datastore
 	emitter        emitterv1.EmitterServiceClient
+	emitterConn    *grpc.ClientConn
+
+	benchmarkInterval time.Duration
+	reputationDecay   float64
+	policies          map[datastore.IncidentCondition]*Policy
+	liveness          datastore.Liveness
+	elector           *election.Elector
+	notifier          Notifier
+
+	hbMutex    sync.RWMutex
+	heartbeats map[string]*heartbeat
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 func New(opts ...Option) (*Manager, error) {
 	offlineTimeout := time.Second * 10
 	ds := &Manager{
 		offlineTimeout: offlineTimeout,
-		offlineTicker:  time.NewTicker(offlineTimeout),
-		wiTicker:       time.NewTicker(time.Second * 30),
-		wrTicker:       time.NewTicker(time.Second * 30),
+
+		benchmarkInterval: time.Hour * 24 * 7,
+		reputationDecay:   0.97,
+		policies:          map[datastore.IncidentCondition]*Policy{},
+		liveness:          datastore.DefaultLiveness,
+		heartbeats:        map[string]*heartbeat{},
 	}
+
+	ds.offlineTicker = ds.newJobTicker("check_offline", offlineTimeout)
+	ds.stuckTicker = ds.newJobTicker("check_stuck_miners", offlineTimeout)
+	ds.wiTicker = ds.newJobTicker("update_worker_info", time.Second*30)
+	ds.wrTicker = ds.newJobTicker("update_worker_reward", time.Second*30)
+	ds.cmdTicker = ds.newJobTicker("expire_commands", time.Minute)
+	ds.resTicker = ds.newJobTicker("expire_reservations", time.Second*10)
+	ds.leaseTicker = ds.newJobTicker("reclaim_leases", time.Second*30)
+	ds.blockTicker = ds.newJobTicker("expire_blocks", time.Minute)
+	ds.benchTicker = ds.newJobTicker("schedule_benchmarks", time.Minute*10)
+	ds.repTicker = ds.newJobTicker("update_reputation", time.Hour)
+
 	for _, o := range opts {
 		if err := o(ds); err != nil {
 			return nil, err
@@ -39,27 +85,113 @@ func New(opts ...Option) (*Manager, error) {
 }
 
 func (m *Manager) Start() {
-	go m.checkOffline()
-	go m.checkStuckMiners()
-	go m.updateWorkerInfo()
-	go m.updateWorkerReward()
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.resetHeartbeats()
+
+	m.run(m.checkOffline)
+	m.run(m.checkStuckMiners)
+	m.run(m.updateWorkerInfo)
+	m.run(m.updateWorkerReward)
+	m.run(m.expireCommands)
+	m.run(m.expireReservations)
+	m.run(m.reclaimLeases)
+	m.run(m.expireBlocks)
+	m.run(m.scheduleBenchmarks)
+	m.run(m.updateReputation)
+}
+
+func (m *Manager) run(job func()) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		job()
+	}()
 }
 
-func (m *Manager) Stop() {
+// Stop cancels the jobs and waits for the running iterations to finish or
+// the context to be done.
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+
 	m.offlineTicker.Stop()
+	m.stuckTicker.Stop()
 	m.wiTicker.Stop()
 	m.wrTicker.Stop()
+	m.cmdTicker.Stop()
+	m.resTicker.Stop()
+	m.leaseTicker.Stop()
+	m.blockTicker.Stop()
+	m.benchTicker.Stop()
+	m.repTicker.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isLeader reports whether this replica runs the background jobs. Without an
+// elector every replica does.
+func (m *Manager) isLeader() bool {
+	return m.elector == nil || m.elector.IsLeader()
 }
 
 func (m *Manager) checkOffline() {
-	for range m.offlineTicker.C {
-		ctx := context.Background()
-		err := m.ds.Miners.MarkAsOffline(ctx, m.offlineTimeout)
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.offlineTicker.C:
+		}
+
+		m.beat("check_offline")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		offline, err := m.ds.Miners.MarkAsOffline(ctx, m.offlineTimeout, m.liveness)
 		if err != nil {
 			m.logger.Errorf("failed to mark miners as offline: %s", err)
 			continue
 		}
 
+		inMaintenance := map[string]bool{}
+		if len(offline) > 0 {
+			inMaintenance, err = m.ds.Miners.ListInMaintenance(ctx)
+			if err != nil {
+				m.logger.Errorf("failed to list miners in maintenance: %s", err)
+				continue
+			}
+		}
+
+		// Going offline is flapping when it makes the miner unstable by
+		// the liveness rules, otherwise it is missed pings.
+		for _, miner := range offline {
+			if isExpectedOffline(miner, inMaintenance[miner.ID], time.Now()) {
+				m.logger.WithField("miner_id", miner.ID).Info("miner went offline as announced")
+				continue
+			}
+
+			transitions, unstable := m.liveness.Transition(miner.Transitions, time.Now())
+			if unstable {
+				m.remediate(ctx, miner, datastore.IncidentConditionFlapping, fmt.Sprintf("%d transitions within %s", len(transitions), m.liveness.Window))
+				continue
+			}
+			m.remediate(ctx, miner, datastore.IncidentConditionMissedPings, "no ping since "+miner.LastPingAt.UTC().Format(time.RFC3339))
+		}
+
 		miners, err := m.ds.Miners.GetStuckMinerList(ctx, m.offlineTimeout)
 		if err != nil {
 			m.logger.Errorf("failed to get stuck miners: %s", err)
@@ -68,19 +200,33 @@ func (m *Manager) checkOffline() {
 
 		if len(miners) > 0 {
 			for _, miner := range miners {
-				err := m.ds.Miners.MarkMinerAsOffline(ctx, miner)
+				err := m.ds.Miners.MarkMinerAsOffline(ctx, miner, m.liveness)
 				if err != nil {
 					m.logger.Errorf("failed to mark miner as offline: %s", err)
 					continue
 				}
+
+				m.remediate(ctx, miner, datastore.IncidentConditionStuckBusy, "busy without ping since "+miner.LastPingAt.UTC().Format(time.RFC3339))
 			}
 		}
 	}
 }
 
 func (m *Manager) updateWorkerInfo() {
-	for range m.wiTicker.C {
-		emptyCtx := context.Background()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.wiTicker.C:
+		}
+
+		m.beat("update_worker_info")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		emptyCtx := m.ctx
 		// miners, err := m.ds.Miners.ListByOnline(emptyCtx)
 		miners, err := m.ds.Miners.List(emptyCtx, nil)
 		if err != nil {
@@ -93,10 +239,14 @@ func (m *Manager) updateWorkerInfo() {
 				continue
 			}
 
+			m.beat("update_worker_info")
+
 			logger := m.logger.WithField("miner_id", miner.ID)
 			if miner.Address.String != "" {
 				workerReq := &emitterv1.WorkerRequest{Address: miner.Address.String}
-				worker, err := m.emitter.GetWorker(emptyCtx, workerReq)
+				callCtx, cancel := context.WithTimeout(emptyCtx, emitterCallTimeout)
+				worker, err := m.emitter.GetWorker(callCtx, workerReq)
+				cancel()
 				if err != nil {
 					st, ok := status.FromError(err)
 					if ok && st.Code() == codes.NotFound {
@@ -132,8 +282,20 @@ func (m *Manager) updateWorkerInfo() {
 }
 
 func (m *Manager) checkStuckMiners() {
-	for range m.offlineTicker.C {
-		ctx := context.Background()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.stuckTicker.C:
+		}
+
+		m.beat("check_stuck_miners")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
 		miners, err := m.ds.Miners.GetStuckOfflineMinerList(ctx, m.offlineTimeout)
 		if err != nil {
 			m.logger.Errorf("failed to get stuck offline miners: %s", err)
@@ -143,19 +305,39 @@ func (m *Manager) checkStuckMiners() {
 		if len(miners) > 0 {
 			for _, miner := range miners {
 				logger := m.logger.WithField("miner_id", miner.ID)
-				err := m.ds.Miners.UpdateCurrentTask(ctx, miner, "", false)
+				lost := miner.ActiveTasks
+				err := m.ds.Miners.UnassignTask(ctx, miner, "")
 				if err != nil {
-					logger.WithError(err).Error("failed to clear current task")
+					logger.WithError(err).Error("failed to clear current tasks")
 					continue
 				}
+
+				err = m.ds.Miners.IncrementTasksLost(ctx, miner)
+				if err != nil {
+					logger.WithError(err).Error("failed to increment lost tasks")
+				}
+
+				m.remediate(ctx, miner, datastore.IncidentConditionTaskLoss, fmt.Sprintf("%d tasks lost", lost))
 			}
 		}
 	}
 }
 
 func (m *Manager) updateWorkerReward() {
-	for range m.wrTicker.C {
-		emptyCtx := context.Background()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.wrTicker.C:
+		}
+
+		m.beat("update_worker_reward")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		emptyCtx := m.ctx
 		miners, err := m.ds.Miners.List(emptyCtx, nil)
 		if err != nil {
 			m.logger.Infof("failed to list workers: %s", err)
@@ -163,10 +345,14 @@ func (m *Manager) updateWorkerReward() {
 		}
 
 		for _, miner := range miners {
+			m.beat("update_worker_reward")
+
 			logger := m.logger.WithField("miner_id", miner.ID)
 			if miner.Address.String != "" {
 				rewardReq := &emitterv1.RewardRequest{Address: miner.Address.String}
-				reward, err := m.emitter.GetReward(emptyCtx, rewardReq)
+				callCtx, cancel := context.WithTimeout(emptyCtx, emitterCallTimeout)
+				reward, err := m.emitter.GetReward(callCtx, rewardReq)
+				cancel()
 				if err != nil {
 					logger.Infof("failed to get worker reward: %s", err)
 					continue
@@ -183,3 +369,229 @@ func (m *Manager) updateWorkerReward() {
 		}
 	}
 }
+
+func (m *Manager) expireCommands() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.cmdTicker.C:
+		}
+
+		m.beat("expire_commands")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		count, err := m.ds.Commands.ExpirePending(ctx)
+		if err != nil {
+			m.logger.Errorf("failed to expire commands: %s", err)
+			continue
+		}
+
+		if count > 0 {
+			m.logger.Infof("expired %d commands", count)
+		}
+	}
+}
+
+func (m *Manager) expireReservations() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.resTicker.C:
+		}
+
+		m.beat("expire_reservations")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		count, err := m.ds.Miners.ExpireReservations(ctx)
+		if err != nil {
+			m.logger.Errorf("failed to expire reservations: %s", err)
+			continue
+		}
+
+		if count > 0 {
+			m.logger.Infof("expired %d reservations", count)
+		}
+	}
+}
+
+func (m *Manager) reclaimLeases() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.leaseTicker.C:
+		}
+
+		m.beat("reclaim_leases")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		count, err := m.ds.Miners.ReclaimExpiredLeases(ctx)
+		if err != nil {
+			m.logger.Errorf("failed to reclaim expired leases: %s", err)
+			continue
+		}
+
+		if count > 0 {
+			m.logger.Warningf("reclaimed %d internal miners with expired leases", count)
+		}
+	}
+}
+
+func (m *Manager) expireBlocks() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.blockTicker.C:
+		}
+
+		m.beat("expire_blocks")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		blocks, err := m.ds.Miners.ListExpiredBlocks(ctx)
+		if err != nil {
+			m.logger.Errorf("failed to list expired blocks: %s", err)
+			continue
+		}
+
+		for _, block := range blocks {
+			logger := m.logger.WithField("miner_id", block.MinerID)
+
+			err := m.ds.Miners.ExpireBlock(ctx, block)
+			if err != nil {
+				logger.WithError(err).Error("failed to expire block")
+				continue
+			}
+
+			logger.Info("block expired")
+		}
+	}
+}
+
+func (m *Manager) scheduleBenchmarks() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.benchTicker.C:
+		}
+
+		m.beat("schedule_benchmarks")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		miners, err := m.ds.Miners.ListBenchmarkDue(ctx, m.benchmarkInterval)
+		if err != nil {
+			m.logger.Errorf("failed to list miners due for benchmark: %s", err)
+			continue
+		}
+
+		for _, miner := range miners {
+			logger := m.logger.WithField("miner_id", miner.ID)
+
+			open, err := m.ds.Commands.HasOpen(ctx, miner.ID, datastore.CommandTypeBenchmark)
+			if err != nil {
+				logger.WithError(err).Error("failed to check benchmark commands")
+				continue
+			}
+			if open {
+				continue
+			}
+
+			// Agents that do not take commands let them expire, they get
+			// one benchmark per interval rather than one per tick.
+			expired, err := m.ds.Commands.CountExpiredSince(ctx, miner.ID, datastore.CommandTypeBenchmark, time.Now().Add(-m.benchmarkInterval))
+			if err != nil {
+				logger.WithError(err).Error("failed to count expired benchmarks")
+				continue
+			}
+			if expired > 0 {
+				continue
+			}
+
+			_, err = m.ds.Commands.Enqueue(ctx, miner.ID, "", datastore.CommandTypeBenchmark, datastore.NewBenchmarkPayload(), time.Hour)
+			if err != nil {
+				logger.WithError(err).Error("failed to enqueue benchmark")
+				continue
+			}
+
+			logger.Info("benchmark enqueued")
+		}
+	}
+}
+
+func (m *Manager) updateReputation() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.repTicker.C:
+		}
+
+		m.beat("update_reputation")
+
+		if !m.isLeader() {
+			continue
+		}
+
+		ctx := m.ctx
+		miners, err := m.ds.Miners.List(ctx, nil)
+		if err != nil {
+			m.logger.Errorf("failed to list miners: %s", err)
+			continue
+		}
+
+		for _, miner := range miners {
+			logger := m.logger.WithField("miner_id", miner.ID)
+
+			since := time.Now().Add(-time.Hour)
+			if miner.Reputation.UpdatedAt != nil {
+				since = *miner.Reputation.UpdatedAt
+			}
+
+			blocks, err := m.ds.Miners.CountBlocksSince(ctx, miner.ID, since)
+			if err != nil {
+				logger.WithError(err).Error("failed to count blocks")
+				continue
+			}
+
+			failures, err := m.ds.Commands.CountFailedSince(ctx, miner.ID, datastore.CommandTypeBenchmark, since)
+			if err != nil {
+				logger.WithError(err).Error("failed to count failed benchmarks")
+				continue
+			}
+
+			events := datastore.ReputationEvents{
+				Online:               miner.Status == v1.MinerStatusIdle || miner.Status == v1.MinerStatusBusy,
+				VerificationFailures: failures,
+				Blocks:               blocks,
+			}
+
+			reputation := miner.Reputation.Next(miner, events, m.reputationDecay)
+			if err := m.ds.Miners.UpdateReputation(ctx, miner, reputation); err != nil {
+				logger.WithError(err).Error("failed to update reputation")
+			}
+		}
+	}
+}
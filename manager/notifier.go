@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"context"
+
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-pkg/mqmux"
+)
+
+// Notifier tells the owner of a miner about an incident.
+type Notifier interface {
+	Notify(ctx context.Context, miner *datastore.Miner, incident *datastore.Incident) error
+}
+
+// MQNotifier publishes incidents to a queue consumed by the notifications
+// service.
+type MQNotifier struct {
+	mq    *mqmux.WorkerMux
+	queue string
+}
+
+func NewMQNotifier(uri, name, queue string) (*MQNotifier, error) {
+	mq, err := mqmux.NewWorkerMux(uri, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mq.Publisher(queue); err != nil {
+		return nil, err
+	}
+
+	return &MQNotifier{mq: mq, queue: queue}, nil
+}
+
+func (n *MQNotifier) Notify(ctx context.Context, miner *datastore.Miner, incident *datastore.Incident) error {
+	return n.mq.Publish(n.queue, map[string]interface{}{
+		"user_id":    miner.UserID,
+		"miner_id":   miner.ID,
+		"miner_name": miner.Name,
+		"condition":  incident.Condition,
+		"actions":    incident.Actions.String,
+		"details":    incident.Details.String,
+		"created_at": incident.CreatedAt,
+	})
+}
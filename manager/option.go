@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 	emitterv1 "github.com/videocoin/cloud-api/emitter/v1"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/election"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 )
@@ -31,6 +32,43 @@ func WithDatastore(ds *datastore.Datastore) Option {
 	}
 }
 
+func WithBenchmarkInterval(interval time.Duration) Option {
+	return func(m *Manager) error {
+		m.benchmarkInterval = interval
+		return nil
+	}
+}
+
+func WithElector(elector *election.Elector) Option {
+	return func(m *Manager) error {
+		m.elector = elector
+		return nil
+	}
+}
+
+func WithLiveness(liveness datastore.Liveness) Option {
+	return func(m *Manager) error {
+		m.liveness = liveness
+		return nil
+	}
+}
+
+func WithPolicies(policies []*Policy) Option {
+	return func(m *Manager) error {
+		for _, p := range policies {
+			m.policies[p.Condition] = p
+		}
+		return nil
+	}
+}
+
+func WithNotifier(notifier Notifier) Option {
+	return func(m *Manager) error {
+		m.notifier = notifier
+		return nil
+	}
+}
+
 func WithEmitterServiceClient(addr string) Option {
 	return func(m *Manager) error {
 		opts := []grpc.DialOption{
@@ -52,6 +90,7 @@ func WithEmitterServiceClient(addr string) Option {
 		if err != nil {
 			return err
 		}
+		m.emitterConn = conn
 		m.emitter = emitterv1.NewEmitterServiceClient(conn)
 		return nil
 	}
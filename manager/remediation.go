@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+// Policy is the reaction configured for a condition. Durations are Go
+// duration strings. A miner is blocked for BlockFor, or indefinitely, once
+// BlockAfter incidents of the condition happened within Window.
+type Policy struct {
+	Condition  datastore.IncidentCondition `json:"condition"`
+	Notify     bool                        `json:"notify"`
+	Quarantine string                      `json:"quarantine"`
+	BlockAfter int                         `json:"block_after"`
+	Window     string                      `json:"window"`
+	BlockFor   string                      `json:"block_for"`
+
+	quarantine time.Duration
+	window     time.Duration
+	blockFor   time.Duration
+}
+
+// ParsePolicies decodes the JSON list of remediation policies.
+func ParsePolicies(s string) ([]*Policy, error) {
+	policies := []*Policy{}
+	if s == "" {
+		return policies, nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &policies); err != nil {
+		return nil, fmt.Errorf("invalid remediation policies: %s", err)
+	}
+
+	for _, p := range policies {
+		switch p.Condition {
+		case datastore.IncidentConditionMissedPings,
+			datastore.IncidentConditionStuckBusy,
+			datastore.IncidentConditionFlapping,
+			datastore.IncidentConditionTaskLoss:
+		default:
+			return nil, fmt.Errorf("invalid remediation policy: unknown condition %q", p.Condition)
+		}
+
+		for _, d := range []struct {
+			value  string
+			target *time.Duration
+		}{
+			{p.Quarantine, &p.quarantine},
+			{p.Window, &p.window},
+			{p.BlockFor, &p.blockFor},
+		} {
+			if d.value == "" {
+				continue
+			}
+			v, err := time.ParseDuration(d.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid remediation policy for %s: %s", p.Condition, err)
+			}
+			*d.target = v
+		}
+		if p.BlockAfter > 0 && p.window == 0 {
+			p.window = time.Hour * 24
+		}
+	}
+
+	return policies, nil
+}
+
+// isExpectedOffline reports whether the owner announced the miner going
+// offline: its availability schedule excludes now, it is draining or it is
+// inside a maintenance window. No remediation applies then.
+func isExpectedOffline(miner *datastore.Miner, inMaintenance bool, now time.Time) bool {
+	return inMaintenance || miner.IsDraining || !miner.Availability.IsAvailable(now)
+}
+
+// remediate records an incident for the miner and applies the policy of the
+// condition, if any.
+func (m *Manager) remediate(ctx context.Context, miner *datastore.Miner, condition datastore.IncidentCondition, details string) {
+	logger := m.logger.WithField("miner_id", miner.ID).WithField("condition", condition)
+
+	actions := []string{}
+	policy := m.policies[condition]
+
+	if policy != nil && policy.quarantine > 0 {
+		if err := m.ds.Miners.Quarantine(ctx, miner, policy.quarantine); err != nil {
+			logger.WithError(err).Error("failed to quarantine miner")
+		} else {
+			actions = append(actions, "quarantine")
+		}
+	}
+
+	if policy != nil && policy.BlockAfter > 0 && !miner.IsBlock {
+		count, err := m.ds.Incidents.CountSince(ctx, miner.ID, condition, time.Now().Add(-policy.window))
+		if err != nil {
+			logger.WithError(err).Error("failed to count incidents")
+		} else if count+1 >= policy.BlockAfter {
+			reason := fmt.Sprintf("%d %s incidents within %s", count+1, condition, policy.window)
+			if _, err := m.ds.Miners.Block(ctx, miner, reason, "remediation", policy.blockFor); err != nil {
+				logger.WithError(err).Error("failed to block miner")
+			} else {
+				actions = append(actions, "block")
+			}
+		}
+	}
+
+	notify := policy != nil && policy.Notify && m.notifier != nil
+	if notify {
+		actions = append(actions, "notify")
+	}
+
+	incident, err := m.ds.Incidents.Create(ctx, miner.ID, condition, details, actions)
+	if err != nil {
+		logger.WithError(err).Error("failed to record incident")
+		return
+	}
+
+	if len(actions) > 0 {
+		logger.Warningf("remediation applied: %v", actions)
+	}
+
+	if notify {
+		if err := m.notifier.Notify(ctx, miner, incident); err != nil {
+			logger.WithError(err).Error("failed to notify owner")
+		}
+	}
+}
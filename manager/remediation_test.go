@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []*Policy
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			s:    "",
+			want: []*Policy{},
+		},
+		{
+			name: "durations",
+			s:    `[{"condition":"missed_pings","quarantine":"15m","block_after":3,"window":"1h","block_for":"24h"}]`,
+			want: []*Policy{{
+				Condition:  datastore.IncidentConditionMissedPings,
+				quarantine: 15 * time.Minute,
+				window:     time.Hour,
+				blockFor:   24 * time.Hour,
+			}},
+		},
+		{
+			name: "default window when blocking",
+			s:    `[{"condition":"flapping","block_after":2}]`,
+			want: []*Policy{{Condition: datastore.IncidentConditionFlapping, window: 24 * time.Hour}},
+		},
+		{
+			name: "no window without blocking",
+			s:    `[{"condition":"task_loss","notify":true}]`,
+			want: []*Policy{{Condition: datastore.IncidentConditionTaskLoss}},
+		},
+		{
+			name:    "not json",
+			s:       `missed_pings`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown condition",
+			s:       `[{"condition":"overheating","notify":true}]`,
+			wantErr: true,
+		},
+		{
+			name:    "bad duration",
+			s:       `[{"condition":"stuck_busy","quarantine":"15 minutes"}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicies(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePolicies() = %d policies, want %d", len(got), len(tt.want))
+			}
+			for i, p := range got {
+				want := tt.want[i]
+				if p.Condition != want.Condition {
+					t.Errorf("condition = %s, want %s", p.Condition, want.Condition)
+				}
+				if p.quarantine != want.quarantine || p.window != want.window || p.blockFor != want.blockFor {
+					t.Errorf("durations = %s/%s/%s, want %s/%s/%s",
+						p.quarantine, p.window, p.blockFor, want.quarantine, want.window, want.blockFor)
+				}
+			}
+		})
+	}
+}
+
+func TestIsExpectedOffline(t *testing.T) {
+	// 2020-06-01 is a Monday.
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	weekdays := datastore.Schedule{Windows: []*datastore.ScheduleWindow{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "09:00", To: "17:00"},
+	}}
+	weekends := datastore.Schedule{Windows: []*datastore.ScheduleWindow{
+		{Days: []string{"sat", "sun"}, From: "00:00", To: "24:00"},
+	}}
+
+	tests := []struct {
+		name          string
+		miner         *datastore.Miner
+		inMaintenance bool
+		want          bool
+	}{
+		{"no schedule", &datastore.Miner{}, false, false},
+		{"inside the schedule", &datastore.Miner{Availability: weekdays}, false, false},
+		{"outside the schedule", &datastore.Miner{Availability: weekends}, false, true},
+		{"draining", &datastore.Miner{IsDraining: true}, false, true},
+		{"in a maintenance window", &datastore.Miner{}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isExpectedOffline(tt.miner, tt.inMaintenance, now); got != tt.want {
+				t.Errorf("isExpectedOffline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	v1 "github.com/videocoin/cloud-api/miners/v1"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/election"
 	"golang.org/x/net/context"
 )
 
@@ -13,28 +14,44 @@ type Collector struct {
 	mutex   sync.RWMutex
 	metrics *Metrics
 	ds      *datastore.Datastore
+	elector *election.Elector
 	ticker  *time.Ticker
+	cancel  context.CancelFunc
+	done    chan struct{}
 }
 
-func NewCollector(namespace string, ds *datastore.Datastore) *Collector {
+func NewCollector(namespace string, ds *datastore.Datastore, elector *election.Elector) *Collector {
 	metrics := NewMetrics(namespace)
 	metrics.RegisterAll()
 	return &Collector{
 		metrics: metrics,
 		ds:      ds,
+		elector: elector,
 		ticker:  time.NewTicker(time.Second * 5),
 	}
 }
 
-func (mc *Collector) Collect() {
-	for range mc.ticker.C {
+func (mc *Collector) Collect(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-mc.ticker.C:
+		}
+
 		mc.mutex.Lock()
-		mc.collectMetrics()
+		if mc.elector == nil || mc.elector.IsLeader() {
+			mc.metrics.leader.Set(1)
+			mc.collectMetrics(ctx)
+		} else {
+			mc.metrics.leader.Set(0)
+			mc.metrics.Reset()
+		}
 		mc.mutex.Unlock()
 	}
 }
 
-func (mc *Collector) collectMetrics() {
+func (mc *Collector) collectMetrics(ctx context.Context) {
 	statuses := []string{
 		v1.MinerStatusNew.String(),
 		v1.MinerStatusIdle.String(),
@@ -44,9 +61,23 @@ func (mc *Collector) collectMetrics() {
 
 	mc.metrics.internalMinerStatus.Reset()
 
-	ctx := context.Background()
 	miners, err := mc.ds.Miners.ListByInternal(ctx)
 	if err == nil {
+		leases := map[string]float64{"free": 0, "leased": 0, "expired": 0}
+		for _, miner := range miners {
+			switch {
+			case miner.IsLeased():
+				leases["leased"]++
+			case miner.LeaseExpiresAt != nil:
+				leases["expired"]++
+			default:
+				leases["free"]++
+			}
+		}
+		for state, count := range leases {
+			mc.metrics.internalMinerLease.WithLabelValues(state).Set(count)
+		}
+
 		for _, miner := range miners {
 			for _, status := range statuses {
 				hostname := ""
@@ -62,13 +93,105 @@ func (mc *Collector) collectMetrics() {
 			}
 		}
 	}
+
+	mc.metrics.minerAgentVersion.Reset()
+
+	versions, err := mc.ds.Miners.CountByVersion(ctx)
+	if err == nil {
+		for version, count := range versions {
+			if version == "" {
+				version = "unknown"
+			}
+			mc.metrics.minerAgentVersion.WithLabelValues(version).Set(float64(count))
+		}
+	}
+
+	mc.collectRolloutMetrics(ctx)
+	mc.collectMaintenanceMetrics(ctx)
+}
+
+func (mc *Collector) collectMaintenanceMetrics(ctx context.Context) {
+	miners, err := mc.ds.Miners.ListDraining(ctx)
+	if err != nil {
+		return
+	}
+
+	states := map[string]float64{"draining": 0, "ready": 0}
+	for _, miner := range miners {
+		if miner.IsReadyForMaintenance() {
+			states["ready"]++
+		} else {
+			states["draining"]++
+		}
+	}
+	for state, count := range states {
+		mc.metrics.minerMaintenance.WithLabelValues(state).Set(count)
+	}
+}
+
+func (mc *Collector) collectRolloutMetrics(ctx context.Context) {
+	mc.metrics.agentRollout.Reset()
+
+	rollouts, err := mc.ds.Rollouts.ListActive(ctx)
+	if err != nil || len(rollouts) == 0 {
+		return
+	}
+
+	selectors, err := mc.ds.Rollouts.ListChannelSelectors(ctx)
+	if err != nil {
+		return
+	}
+
+	miners, err := mc.ds.Miners.List(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	targeted := map[string]int{}
+	upgraded := map[string]int{}
+	for _, miner := range miners {
+		channel := datastore.ResolveChannel(miner, selectors)
+		rollout := datastore.ResolveRollout(miner, channel, rollouts)
+		if rollout == nil {
+			continue
+		}
+
+		targeted[rollout.ID]++
+		if miner.Version.String == rollout.TargetVersion {
+			upgraded[rollout.ID]++
+		}
+	}
+
+	for _, rollout := range rollouts {
+		labels := []string{rollout.ID, string(rollout.Channel), rollout.TargetVersion}
+		mc.metrics.agentRollout.WithLabelValues(append(labels, "targeted")...).Set(float64(targeted[rollout.ID]))
+		mc.metrics.agentRollout.WithLabelValues(append(labels, "upgraded")...).Set(float64(upgraded[rollout.ID]))
+	}
 }
 
 func (mc *Collector) Start() {
-	go mc.Collect()
+	ctx, cancel := context.WithCancel(context.Background())
+	mc.cancel = cancel
+	mc.done = make(chan struct{})
+
+	go func() {
+		defer close(mc.done)
+		mc.Collect(ctx)
+	}()
 }
 
-func (mc *Collector) Stop() error {
+func (mc *Collector) Stop(ctx context.Context) error {
 	mc.ticker.Stop()
-	return nil
+	if mc.cancel == nil {
+		return nil
+	}
+
+	mc.cancel()
+
+	select {
+	case <-mc.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/videocoin/cloud-miners/health"
+)
+
+// healthz reports whether the process is alive, i.e. its background jobs
+// keep running.
+func (s *Server) healthz(c echo.Context) error {
+	return s.writeReport(c, s.checker.Liveness())
+}
+
+// readyz reports whether the replica can serve traffic with a breakdown of
+// every dependency check. It fails as soon as the service starts shutting
+// down.
+func (s *Server) readyz(c echo.Context) error {
+	return s.writeReport(c, s.checker.Readiness())
+}
+
+func (s *Server) writeReport(c echo.Context, report *health.Report) error {
+	code := http.StatusOK
+	if report.Status == health.StatusFailed {
+		code = http.StatusServiceUnavailable
+	}
+
+	return c.JSON(code, report)
+}
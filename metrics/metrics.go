@@ -6,6 +6,11 @@ import (
 
 type Metrics struct {
 	internalMinerStatus *prometheus.GaugeVec
+	minerAgentVersion   *prometheus.GaugeVec
+	agentRollout        *prometheus.GaugeVec
+	internalMinerLease  *prometheus.GaugeVec
+	minerMaintenance    *prometheus.GaugeVec
+	leader              prometheus.Gauge
 }
 
 func NewMetrics(namespace string) *Metrics {
@@ -18,9 +23,63 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"status", "hostname"},
 		),
+		minerAgentVersion: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "miner_agent_version",
+				Help:      "Number of miners by agent version",
+			},
+			[]string{"version"},
+		),
+		agentRollout: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "agent_rollout_miners",
+				Help:      "Number of miners targeted and upgraded by an active rollout",
+			},
+			[]string{"rollout_id", "channel", "target_version", "state"},
+		),
+		internalMinerLease: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "internal_miner_lease",
+				Help:      "Number of internal miners by lease state",
+			},
+			[]string{"state"},
+		),
+		minerMaintenance: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "miner_maintenance",
+				Help:      "Number of draining miners and of miners ready for maintenance",
+			},
+			[]string{"state"},
+		),
+		leader: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "leader",
+				Help:      "Whether the replica runs the background jobs",
+			},
+		),
 	}
 }
 
 func (m *Metrics) RegisterAll() {
 	prometheus.MustRegister(m.internalMinerStatus)
+	prometheus.MustRegister(m.minerAgentVersion)
+	prometheus.MustRegister(m.agentRollout)
+	prometheus.MustRegister(m.internalMinerLease)
+	prometheus.MustRegister(m.minerMaintenance)
+	prometheus.MustRegister(m.leader)
+}
+
+// Reset clears the gauges computed from the database, which only the leader
+// reports.
+func (m *Metrics) Reset() {
+	m.internalMinerStatus.Reset()
+	m.minerAgentVersion.Reset()
+	m.agentRollout.Reset()
+	m.internalMinerLease.Reset()
+	m.minerMaintenance.Reset()
 }
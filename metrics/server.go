@@ -1,9 +1,14 @@
 package metrics
 
 import (
+	"context"
+	"net/http"
+
 	"github.com/labstack/echo"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/admin"
+	"github.com/videocoin/cloud-miners/health"
 )
 
 type ServerConfig struct {
@@ -11,34 +16,48 @@ type ServerConfig struct {
 }
 
 type Server struct {
-	logger *logrus.Entry
-	addr   string
-	e      *echo.Echo
+	logger  *logrus.Entry
+	addr    string
+	e       *echo.Echo
+	checker *health.Checker
+	admin   *admin.API
 }
 
-func NewServer(addr string, logger *logrus.Entry) (*Server, error) {
+func NewServer(addr string, logger *logrus.Entry, checker *health.Checker, adminAPI *admin.API) (*Server, error) {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 	e.DisableHTTP2 = true
 
 	return &Server{
-		logger: logger,
-		addr:   addr,
-		e:      e,
+		logger:  logger,
+		addr:    addr,
+		e:       e,
+		checker: checker,
+		admin:   adminAPI,
 	}, nil
 }
 
 func (s *Server) Start() error {
 	s.logger.Infof("metrics server listening on %s", s.addr)
 	s.routes()
-	return s.e.Start(s.addr)
+	err := s.e.Start(s.addr)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
-func (s *Server) Stop() error {
-	return nil
+func (s *Server) Stop(ctx context.Context) error {
+	return s.e.Shutdown(ctx)
 }
 
 func (s *Server) routes() {
 	s.e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	s.e.GET("/healthz", s.healthz)
+	s.e.GET("/readyz", s.readyz)
+
+	if s.admin != nil {
+		s.admin.Register(s.e)
+	}
 }
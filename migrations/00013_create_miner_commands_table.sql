@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `miner_commands` (
+  `id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) NOT NULL,
+  `user_id` varchar(255) DEFAULT NULL,
+  `type` varchar(100) NOT NULL,
+  `payload` JSON DEFAULT NULL,
+  `status` varchar(100) NOT NULL,
+  `result` JSON DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `delivered_at` timestamp NULL DEFAULT NULL,
+  `completed_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `miner_commands_miner_id_status` (`miner_id`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE miner_commands;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `version` VARCHAR(100) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `miner_versions` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `miner_id` varchar(255) NOT NULL,
+  `version` varchar(100) NOT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `miner_versions_miner_id` (`miner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE miner_versions;
+ALTER TABLE miners DROP `version`;
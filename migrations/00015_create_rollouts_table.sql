@@ -0,0 +1,31 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `release_channel` VARCHAR(100) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `release_channel_selectors` (
+  `id` varchar(255) NOT NULL,
+  `channel` varchar(100) NOT NULL,
+  `selector` JSON DEFAULT NULL,
+  `priority` int(11) NOT NULL DEFAULT 0,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `rollouts` (
+  `id` varchar(255) NOT NULL,
+  `channel` varchar(100) NOT NULL,
+  `target_version` varchar(100) NOT NULL,
+  `percentage` int(11) NOT NULL DEFAULT 0,
+  `hw` varchar(100) DEFAULT NULL,
+  `is_active` TINYINT(1) DEFAULT 1,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `rollouts_channel_is_active` (`channel`, `is_active`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE rollouts;
+DROP TABLE release_channel_selectors;
+ALTER TABLE miners DROP `release_channel`;
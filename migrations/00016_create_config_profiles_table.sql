@@ -0,0 +1,30 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `config_profiles` (
+  `id` varchar(255) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `schema_version` int(11) NOT NULL DEFAULT 1,
+  `revision` int(11) NOT NULL DEFAULT 1,
+  `document` JSON DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `config_profiles_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `config_profile_assignments` (
+  `id` varchar(255) NOT NULL,
+  `profile_id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) DEFAULT NULL,
+  `user_id` varchar(255) DEFAULT NULL,
+  `selector` JSON DEFAULT NULL,
+  `priority` int(11) NOT NULL DEFAULT 0,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `config_profile_assignments_profile_id` (`profile_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE config_profile_assignments;
+DROP TABLE config_profiles;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `online_at` timestamp NULL DEFAULT NULL;
+ALTER TABLE miners ADD `tasks_assigned` INT(11) NOT NULL DEFAULT 0;
+ALTER TABLE miners ADD `tasks_lost` INT(11) NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `online_at`;
+ALTER TABLE miners DROP `tasks_assigned`;
+ALTER TABLE miners DROP `tasks_lost`;
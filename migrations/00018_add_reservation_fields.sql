@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `reserved_task_id` VARCHAR(255) DEFAULT NULL;
+ALTER TABLE miners ADD `reserved_until` timestamp NULL DEFAULT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `reserved_task_id`;
+ALTER TABLE miners DROP `reserved_until`;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `lease_holder` VARCHAR(255) DEFAULT NULL;
+ALTER TABLE miners ADD `lease_acquired_at` timestamp NULL DEFAULT NULL;
+ALTER TABLE miners ADD `lease_expires_at` timestamp NULL DEFAULT NULL;
+UPDATE miners SET `lease_acquired_at` = NOW(), `lease_expires_at` = NOW() + INTERVAL 5 MINUTE WHERE `is_lock` = 1;
+ALTER TABLE miners DROP `is_lock`;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners ADD `is_lock` TINYINT(1) DEFAULT 0;
+UPDATE miners SET `is_lock` = 1 WHERE `lease_expires_at` > NOW();
+ALTER TABLE miners DROP `lease_holder`;
+ALTER TABLE miners DROP `lease_acquired_at`;
+ALTER TABLE miners DROP `lease_expires_at`;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `miner_tasks` (
+  `miner_id` varchar(255) NOT NULL,
+  `task_id` varchar(255) NOT NULL,
+  `slot` int(11) NOT NULL DEFAULT 0,
+  `assigned_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`miner_id`, `task_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE miners ADD `task_slots` INT(11) NOT NULL DEFAULT 1;
+ALTER TABLE miners ADD `active_tasks` INT(11) NOT NULL DEFAULT 0;
+
+INSERT INTO miner_tasks (`miner_id`, `task_id`, `slot`, `assigned_at`)
+  SELECT `id`, `current_task_id`, 0, NOW() FROM miners WHERE `current_task_id` IS NOT NULL;
+UPDATE miners SET `active_tasks` = 1 WHERE `current_task_id` IS NOT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `task_slots`;
+ALTER TABLE miners DROP `active_tasks`;
+DROP TABLE miner_tasks;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `miner_pins` (
+  `id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) NOT NULL,
+  `task_id` varchar(255) NOT NULL,
+  `created_by` varchar(255) DEFAULT NULL,
+  `reason` TEXT DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `cancelled_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `miner_pins_miner_id` (`miner_id`),
+  KEY `miner_pins_task_id` (`task_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO miner_pins (`id`, `miner_id`, `task_id`, `created_by`, `reason`, `created_at`)
+  SELECT UUID(), `id`, JSON_UNQUOTE(JSON_EXTRACT(`tags`, '$.force_task_id')), NULL, 'migrated from force_task_id tag', NOW()
+  FROM miners
+  WHERE JSON_EXTRACT(`tags`, '$.force_task_id') IS NOT NULL AND JSON_UNQUOTE(JSON_EXTRACT(`tags`, '$.force_task_id')) != '';
+UPDATE miners SET `tags` = JSON_REMOVE(`tags`, '$.force_task_id') WHERE JSON_EXTRACT(`tags`, '$.force_task_id') IS NOT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+UPDATE miners m JOIN miner_pins p ON p.miner_id = m.id
+  SET m.`tags` = JSON_SET(IFNULL(m.`tags`, JSON_OBJECT()), '$.force_task_id', p.`task_id`)
+  WHERE p.`cancelled_at` IS NULL AND (p.`expires_at` IS NULL OR p.`expires_at` > NOW());
+DROP TABLE miner_pins;
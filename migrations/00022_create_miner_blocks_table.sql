@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `miner_blocks` (
+  `id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) NOT NULL,
+  `reason` TEXT DEFAULT NULL,
+  `blocked_by` varchar(255) DEFAULT NULL,
+  `blocked_at` timestamp NULL DEFAULT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `unblocked_by` varchar(255) DEFAULT NULL,
+  `unblocked_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `miner_blocks_miner_id` (`miner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO miner_blocks (`id`, `miner_id`, `reason`, `blocked_at`)
+  SELECT UUID(), `id`, 'blocked before block history was recorded', NOW() FROM miners WHERE `is_block` = 1;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE miner_blocks;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `is_draining` TINYINT(1) DEFAULT 0;
+ALTER TABLE miners ADD `drain_requested_at` timestamp NULL DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `maintenance_windows` (
+  `id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) NOT NULL,
+  `starts_at` timestamp NULL DEFAULT NULL,
+  `ends_at` timestamp NULL DEFAULT NULL,
+  `created_by` varchar(255) DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `maintenance_windows_miner_id` (`miner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE maintenance_windows;
+ALTER TABLE miners DROP `is_draining`;
+ALTER TABLE miners DROP `drain_requested_at`;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `verified_encode_capacity` double DEFAULT NULL;
+ALTER TABLE miners ADD `verified_at` timestamp NULL DEFAULT NULL;
+ALTER TABLE miners ADD `capacity_diverged` TINYINT(1) DEFAULT 0;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `verified_encode_capacity`;
+ALTER TABLE miners DROP `verified_at`;
+ALTER TABLE miners DROP `capacity_diverged`;
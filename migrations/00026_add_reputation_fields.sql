@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `reputation_score` double DEFAULT NULL;
+ALTER TABLE miners ADD `reputation` json DEFAULT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `reputation_score`;
+ALTER TABLE miners DROP `reputation`;
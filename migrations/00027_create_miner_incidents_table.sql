@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS `miner_incidents` (
+  `id` varchar(255) NOT NULL,
+  `miner_id` varchar(255) NOT NULL,
+  `condition` varchar(255) NOT NULL,
+  `actions` varchar(255) DEFAULT NULL,
+  `details` text DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `miner_incidents_miner_id_condition` (`miner_id`, `condition`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE miners ADD `quarantined_until` timestamp NULL DEFAULT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `quarantined_until`;
+DROP TABLE miner_incidents;
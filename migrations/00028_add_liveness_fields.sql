@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE miners ADD `consecutive_pings` INT DEFAULT 0;
+ALTER TABLE miners ADD `transitions` json DEFAULT NULL;
+ALTER TABLE miners ADD `is_unstable` TINYINT(1) DEFAULT 0;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE miners DROP `consecutive_pings`;
+ALTER TABLE miners DROP `transitions`;
+ALTER TABLE miners DROP `is_unstable`;
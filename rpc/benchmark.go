@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+)
+
+// recordBenchmark verifies a benchmark result reported by the agent against
+// the command it answers and stores the encode capacity it measures. The
+// throughput is computed here from the reference workload, the agent only
+// reports how long it took.
+func (s *Server) recordBenchmark(ctx context.Context, logger *logrus.Entry, miner *datastore.Miner, raw interface{}) {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		logger.Errorf("failed to marshal benchmark result: %s", err)
+		return
+	}
+
+	result := &datastore.BenchmarkResult{}
+	if err := json.Unmarshal(b, result); err != nil {
+		logger.Errorf("failed to unmarshal benchmark result: %s", err)
+		return
+	}
+
+	logger = logger.WithField("command_id", result.CommandID)
+
+	command, err := s.ds.Commands.Get(ctx, result.CommandID, miner.ID)
+	if err != nil {
+		logger.Warningf("failed to get benchmark command: %s", err)
+		return
+	}
+
+	if command.Type != datastore.CommandTypeBenchmark || command.Status != datastore.CommandStatusDelivered {
+		logger.Warningf("benchmark result for %s %s command is ignored", command.Status, command.Type)
+		return
+	}
+
+	elapsed := time.Duration(result.ElapsedMs) * time.Millisecond
+	valid := result.Frames == datastore.BenchmarkFrames &&
+		elapsed > 0 &&
+		command.DeliveredAt != nil &&
+		elapsed <= time.Since(*command.DeliveredAt)
+
+	err = s.ds.Commands.Ack(ctx, command, valid, datastore.Info{
+		"frames":     result.Frames,
+		"elapsed_ms": result.ElapsedMs,
+	})
+	if err != nil {
+		logger.Errorf("failed to ack benchmark command: %s", err)
+		return
+	}
+
+	if !valid {
+		logger.Warningf("benchmark result is rejected")
+		return
+	}
+
+	encode := datastore.BenchmarkFrames / elapsed.Seconds()
+	if err := s.ds.Miners.RecordBenchmark(ctx, miner, encode, s.benchmarkTolerance); err != nil {
+		logger.Errorf("failed to record benchmark: %s", err)
+		return
+	}
+
+	if miner.CapacityDiverged {
+		logger.Warningf("claimed encode capacity %v diverges from verified %.2f", miner.CapacityInfo["encode"], encode)
+	}
+}
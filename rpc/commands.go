@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxOwnerCommands is how many commands owners get with their miner.
+const maxOwnerCommands = 20
+
+// ownerCommandTypes are the commands owners may enqueue, benchmarks are
+// scheduled by the service only.
+var ownerCommandTypes = map[datastore.CommandType]bool{
+	datastore.CommandTypeRestart:           true,
+	datastore.CommandTypeUpgrade:           true,
+	datastore.CommandTypeUploadDiagnostics: true,
+}
+
+func (s *Server) ackCommand(ctx context.Context, logger *logrus.Entry, miner *datastore.Miner, result *ext.CommandResult) {
+	command, err := s.ds.Commands.Get(ctx, result.ID, miner.ID)
+	if err != nil {
+		logger.Warningf("failed to get command: %s", err)
+		return
+	}
+
+	if command.Status != datastore.CommandStatusDelivered {
+		logger.Warningf("result for %s command is ignored", command.Status)
+		return
+	}
+
+	// Successful benchmarks are verified with the capacity info they come
+	// with, see recordBenchmark.
+	if command.Type == datastore.CommandTypeBenchmark && result.Succeeded {
+		logger.Warning("benchmark result without capacity info is ignored")
+		return
+	}
+
+	if err := s.ds.Commands.Ack(ctx, command, result.Succeeded, datastore.Info(result.Result)); err != nil {
+		logger.Errorf("failed to ack command: %s", err)
+	}
+}
+
+// enqueueCommand enqueues the command an owner asked for on their miner.
+func (s *Server) enqueueCommand(ctx context.Context, miner *datastore.Miner, userID string, req *ext.CommandRequest) error {
+	commandType, err := datastore.ParseCommandType(req.Type)
+	if err != nil || !ownerCommandTypes[commandType] {
+		return status.Errorf(codes.InvalidArgument, "invalid command type %q", req.Type)
+	}
+
+	ttl := datastore.DefaultCommandTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	_, err = s.ds.Commands.Enqueue(ctx, miner.ID, userID, commandType, datastore.Info(req.Payload), ttl)
+	return err
+}
+
+func (s *Server) listOwnerCommands(ctx context.Context, miner *datastore.Miner) ([]*ext.Command, error) {
+	commands, err := s.ds.Commands.List(ctx, miner.ID, &datastore.ListFilter{Limit: pointer.ToInt(maxOwnerCommands)})
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*ext.Command{}
+	for _, command := range commands {
+		items = append(items, toCommand(command))
+	}
+
+	return items, nil
+}
+
+func toCommand(command *datastore.Command) *ext.Command {
+	return &ext.Command{
+		ID:          command.ID,
+		Type:        string(command.Type),
+		Payload:     command.Payload,
+		Status:      string(command.Status),
+		Result:      command.Result,
+		CreatedAt:   command.CreatedAt,
+		ExpiresAt:   command.ExpiresAt,
+		DeliveredAt: command.DeliveredAt,
+		CompletedAt: command.CompletedAt,
+	}
+}
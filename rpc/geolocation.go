@@ -1,23 +1,49 @@
 package rpc
 
 import (
+	"fmt"
 	"net"
 
 	"github.com/oschwald/geoip2-golang"
 )
 
-func GetLatLon(ip string) (float64, float64, error) {
-	db, err := geoip2.Open("/data/GeoLite2.mmdb")
+// GeoIPDatabasePath is the GeoLite2 city database used to locate miners.
+const GeoIPDatabasePath = "/data/GeoLite2.mmdb"
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	Country   string
+	Continent string
+}
+
+func GetLocation(ip string) (*Location, error) {
+	db, err := geoip2.Open(GeoIPDatabasePath)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 	defer db.Close()
 
 	parsedIP := net.ParseIP(ip)
 	record, err := db.City(parsedIP)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
+	}
+
+	return &Location{
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+		Country:   record.Country.IsoCode,
+		Continent: record.Continent.Code,
+	}, nil
+}
+
+// CheckGeoIP fails when the GeoIP database is missing or cannot be read.
+func CheckGeoIP() error {
+	db, err := geoip2.Open(GeoIPDatabasePath)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database: %s", err)
 	}
 
-	return record.Location.Latitude, record.Location.Longitude, nil
+	return db.Close()
 }
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/mailru/dbr"
 
 	"github.com/AlekSi/pointer"
@@ -9,8 +10,22 @@ import (
 	v1 "github.com/videocoin/cloud-api/miners/v1"
 	"github.com/videocoin/cloud-api/rpc"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// maxOwnerIncidents is how many incidents owners get with their miner.
+const maxOwnerIncidents = 20
+
+// ownerTags are the SetTags keys only the owner of the miner may set.
+var ownerTags = map[string]bool{
+	ext.TagAvailability: true,
+	ext.TagCommand:      true,
+	ext.TagDrain:        true,
+	ext.TagMaintenance:  true,
+}
+
 func (s *Server) Create(ctx context.Context, req *v1.CreateMinerRequest) (*v1.MinerResponse, error) {
 	userID, err := s.authenticate(ctx)
 	if err != nil {
@@ -78,7 +93,13 @@ func (s *Server) Get(ctx context.Context, req *v1.MinerRequest) (*v1.MinerRespon
 		return nil, err
 	}
 
-	return toMinerResponse(miner), nil
+	resp := toMinerResponse(miner)
+
+	if err := s.addOwnerTags(ctx, miner, resp.Tags); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
 }
 
 func (s *Server) Update(ctx context.Context, req *v1.UpdateMinerRequest) (*v1.MinerResponse, error) {
@@ -157,7 +178,7 @@ func (s *Server) SetTags(ctx context.Context, req *v1.SetTagsRequest) (*v1.Miner
 	span.SetTag("id", req.Id)
 	span.SetTag("tags", req.Tags)
 
-	_, err := s.authenticate(ctx)
+	userID, err := s.authenticate(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -171,8 +192,76 @@ func (s *Server) SetTags(ctx context.Context, req *v1.SetTagsRequest) (*v1.Miner
 		return nil, err
 	}
 
-	if req.Tags != nil && len(req.Tags) > 0 {
-		err = s.ds.Miners.SetTags(ctx, miner, req.Tags)
+	// Settings of the miner are left to its owner.
+	for _, tag := range req.Tags {
+		if ownerTags[tag.Key] && miner.UserID != userID {
+			return nil, rpc.ErrRpcPermissionDenied
+		}
+	}
+
+	// force_task_id is kept for older clients and is stored as a pin
+	// instead of a tag. availability carries the owner's weekly schedule,
+	// drain and maintenance take the miner out of scheduling and command
+	// enqueues a command on the owner's miner.
+	tags := []*v1.Tag{}
+	for _, tag := range req.Tags {
+		if tag.Key == ext.TagAvailability {
+			schedule := datastore.Schedule{}
+			if tag.Value != "" {
+				schedule, err = datastore.ParseSchedule(tag.Value)
+				if err != nil {
+					return nil, status.Error(codes.InvalidArgument, err.Error())
+				}
+			}
+			err = s.ds.Miners.UpdateAvailability(ctx, miner, schedule)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if tag.Key == ext.TagDrain {
+			if err := s.setDrain(ctx, miner, tag.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if tag.Key == ext.TagMaintenance {
+			if err := s.setMaintenance(ctx, miner, userID, tag.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if tag.Key == ext.TagCommand {
+			commandReq := &ext.CommandRequest{}
+			if err := json.Unmarshal([]byte(tag.Value), commandReq); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %s", ext.TagCommand, err)
+			}
+			if err := s.enqueueCommand(ctx, miner, userID, commandReq); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if tag.Key != ext.TagForceTaskID {
+			tags = append(tags, tag)
+			continue
+		}
+
+		if tag.Value == "" {
+			err = s.ds.Pins.CancelByMiner(ctx, miner.ID, "")
+		} else {
+			_, err = s.ds.Pins.Create(ctx, miner.ID, tag.Value, userID, "set with force_task_id tag", 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(tags) > 0 {
+		err = s.ds.Miners.SetTags(ctx, miner, tags)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) setDrain(ctx context.Context, miner *datastore.Miner, value string) error {
+	drain, err := strconv.ParseBool(value)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %s", ext.TagDrain, err)
+	}
+
+	if drain {
+		return s.ds.Miners.Drain(ctx, miner)
+	}
+
+	return s.ds.Miners.Undrain(ctx, miner)
+}
+
+func (s *Server) setMaintenance(ctx context.Context, miner *datastore.Miner, userID string, value string) error {
+	req := &ext.MaintenanceRequest{}
+	if err := json.Unmarshal([]byte(value), req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %s", ext.TagMaintenance, err)
+	}
+
+	if req.Delete != "" {
+		return s.ds.Miners.DeleteMaintenanceWindow(ctx, miner.ID, req.Delete)
+	}
+
+	if req.StartsAt == nil || req.EndsAt == nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: starts_at and ends_at are required", ext.TagMaintenance)
+	}
+	if !req.EndsAt.After(*req.StartsAt) {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: window must end after it starts", ext.TagMaintenance)
+	}
+
+	_, err := s.ds.Miners.CreateMaintenanceWindow(ctx, miner, *req.StartsAt, *req.EndsAt, userID)
+	return err
+}
+
+func (s *Server) listMaintenanceWindows(ctx context.Context, miner *datastore.Miner) ([]*ext.MaintenanceWindow, error) {
+	windows, err := s.ds.Miners.ListMaintenanceWindows(ctx, miner.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*ext.MaintenanceWindow{}
+	for _, window := range windows {
+		items = append(items, &ext.MaintenanceWindow{
+			ID:       window.ID,
+			StartsAt: window.StartsAt,
+			EndsAt:   window.EndsAt,
+		})
+	}
+
+	return items, nil
+}
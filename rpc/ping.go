@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exchangePing stores the command results reported by the agent and answers
+// in the response header with its pending commands, the agent version it
+// should upgrade to and its configuration profile when it changed. Agents
+// that do not send the ping document get nothing.
+func (s *Server) exchangePing(ctx context.Context, logger *logrus.Entry, miner *datastore.Miner) error {
+	req := &ext.PingRequest{}
+	ok, err := ext.FromIncomingContext(ctx, ext.KeyPing, req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !ok {
+		return nil
+	}
+
+	for _, result := range req.Results {
+		s.ackCommand(ctx, logger.WithField("command_id", result.ID), miner, result)
+	}
+
+	commands, err := s.ds.Commands.Deliver(ctx, miner.ID)
+	if err != nil {
+		return err
+	}
+
+	resp := &ext.PingResponse{Commands: []*ext.Command{}}
+	for _, command := range commands {
+		resp.Commands = append(resp.Commands, toCommand(command))
+	}
+
+	targetVersion, err := s.ds.Rollouts.TargetVersion(ctx, miner)
+	if err != nil {
+		return err
+	}
+	if targetVersion != miner.Version.String {
+		resp.TargetVersion = targetVersion
+	}
+
+	profile, err := s.ds.Profiles.Effective(ctx, miner)
+	if err != nil {
+		return err
+	}
+	if profile != nil && (profile.Name != req.ConfigProfile || profile.Revision != req.ConfigRevision) {
+		resp.ConfigProfile = toConfigProfile(profile)
+	}
+
+	return ext.SetHeader(ctx, ext.KeyPing, resp)
+}
+
+func toConfigProfile(profile *datastore.ConfigProfile) *ext.ConfigProfile {
+	return &ext.ConfigProfile{
+		Name:          profile.Name,
+		SchemaVersion: profile.SchemaVersion,
+		Revision:      profile.Revision,
+		Document:      profile.Document,
+	}
+}
+
+// recordTelemetry stores the system info, with the location of the miner
+// address, and the capacity info reported by the agent, verifying the
+// benchmark result the capacity info may come with.
+func (s *Server) recordTelemetry(ctx context.Context, logger *logrus.Entry, miner *datastore.Miner, systemInfo, capacityInfo []byte) {
+	if len(systemInfo) > 0 {
+		sysInfo := map[string]interface{}{}
+		if err := json.Unmarshal(systemInfo, &sysInfo); err != nil {
+			logger.Errorf("failed to unmarshal system info: %s", err)
+		} else {
+			if ip, ok := sysInfo["ip"].(string); ok {
+				location, err := GetLocation(ip)
+				if err != nil {
+					logger.WithField("ip", ip).Errorf("failed to get location by ip: %s", err)
+				} else {
+					geoInfo := map[string]interface{}{
+						"latitude":  location.Latitude,
+						"longitude": location.Longitude,
+						"country":   location.Country,
+						"continent": location.Continent,
+					}
+
+					if err := s.ds.Miners.UpdateGeolocation(ctx, miner, geoInfo); err != nil {
+						logger.Errorf("failed to update geolocation: %s", err)
+					}
+
+					sysInfo["geo"] = geoInfo
+				}
+			}
+
+			if err := s.ds.Miners.UpdateSystemInfo(ctx, miner, sysInfo); err != nil {
+				logger.Errorf("failed to update system info: %s", err)
+			}
+		}
+	}
+
+	if len(capacityInfo) > 0 {
+		capInfo := map[string]interface{}{}
+		if err := json.Unmarshal(capacityInfo, &capInfo); err != nil {
+			logger.Errorf("failed to unmarshal capacity info: %s", err)
+		}
+
+		benchmark, hasBenchmark := capInfo["benchmark"]
+		delete(capInfo, "benchmark")
+
+		if err := s.ds.Miners.UpdateCapacityInfo(ctx, miner, capInfo); err != nil {
+			logger.Errorf("failed to update capacity info: %s", err)
+		}
+
+		if hasBenchmark {
+			s.recordBenchmark(ctx, logger, miner, benchmark)
+		}
+	}
+}
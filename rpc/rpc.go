@@ -3,14 +3,19 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"net"
+	"time"
 
 	protoempty "github.com/gogo/protobuf/types"
 	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
 	v1 "github.com/videocoin/cloud-api/miners/v1"
 	"github.com/videocoin/cloud-api/rpc"
+	"github.com/videocoin/cloud-miners/candidates"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -20,10 +25,12 @@ func (s *Server) Register(ctx context.Context, req *v1.RegistrationRequest) (*v1
 	span.SetTag("address", req.Address)
 	span.SetTag("is_rpi", req.IsRaspberry)
 	span.SetTag("is_jetson", req.IsJetson)
+	span.SetTag("version", req.Version)
 
 	logger := s.logger.WithFields(logrus.Fields{
 		"client_id": req.ClientID,
 		"address":   req.Address,
+		"version":   req.Version,
 	})
 
 	resp := &v1.MinerResponse{}
@@ -37,14 +44,40 @@ func (s *Server) Register(ctx context.Context, req *v1.RegistrationRequest) (*v1
 	}
 
 	defer func() {
-		err = s.ds.Miners.Unlock(ctx, miner)
+		err = s.ds.Miners.ReleaseLease(ctx, miner)
 		if err != nil {
-			logger.Errorf("failed to unlock miner: %s", err)
+			logger.Errorf("failed to release miner lease: %s", err)
 		}
 	}()
 
 	logger.Infof("miner status is %s", miner.Status.String())
 
+	if miner.IsBlock {
+		block, err := s.ds.Miners.GetActiveBlock(ctx, miner)
+		if err != nil {
+			logger.Errorf("failed to get active block: %s", err)
+			return nil, err
+		}
+
+		logger.Warningf("miner is blocked")
+		return nil, blockedError(block)
+	}
+
+	if err := s.versionPolicy.Check(req.Version); err != nil {
+		logger.Warningf("agent version is rejected: %s", err)
+		return nil, err
+	}
+
+	// Worker info is only known once the miner registered with an address
+	// and the manager fetched it from the emitter.
+	if miner.WorkerInfo != nil {
+		eligibility := s.candidates.Eligibility()
+		if reason := eligibility.Check(miner); reason != "" {
+			logger.Warningf("miner is not eligible: %s", reason)
+			return nil, status.Errorf(codes.FailedPrecondition, "miner is not eligible: %s", reason)
+		}
+	}
+
 	if miner.Status == v1.MinerStatusIdle || miner.Status == v1.MinerStatusBusy {
 		logger.Warningf("miner is already running")
 		return nil, status.Errorf(codes.AlreadyExists, "miner is already running")
@@ -87,13 +120,19 @@ func (s *Server) Register(ctx context.Context, req *v1.RegistrationRequest) (*v1
 		return nil, err
 	}
 
+	err = s.ds.Miners.UpdateVersion(ctx, miner, req.Version)
+	if err != nil {
+		logger.Errorf("failed to update version: %s", err)
+		return nil, err
+	}
+
 	var tags []*v1.Tag
 	if req.IsRaspberry {
-		tags = []*v1.Tag{{Key: "hw", Value: "raspberrypi"}}
+		tags = []*v1.Tag{{Key: ext.TagHardware, Value: "raspberrypi"}}
 	} else if req.IsJetson {
-		tags = []*v1.Tag{{Key: "hw", Value: "jetson"}}
+		tags = []*v1.Tag{{Key: ext.TagHardware, Value: "jetson"}}
 	} else {
-		tags = []*v1.Tag{{Key: "hw", Value: ""}}
+		tags = []*v1.Tag{{Key: ext.TagHardware, Value: ""}}
 	}
 
 	if len(tags) > 0 {
@@ -104,7 +143,7 @@ func (s *Server) Register(ctx context.Context, req *v1.RegistrationRequest) (*v1
 		}
 	}
 
-	err = s.ds.Miners.MarkMinerAsIdle(ctx, miner)
+	err = s.ds.Miners.MarkMinerAsIdle(ctx, miner, s.liveness)
 	if err != nil {
 		logger.Errorf("failed to mark miner as idle: %s", err)
 		return nil, err
@@ -113,12 +152,50 @@ func (s *Server) Register(ctx context.Context, req *v1.RegistrationRequest) (*v1
 	resp.Id = miner.ID
 	resp.Name = miner.Name
 	resp.Status = miner.Status
-	resp.Tags = miner.Tags
+	resp.Tags = s.registrationTags(ctx, logger, miner)
 	resp.UserID = miner.UserID
 
 	return resp, nil
 }
 
+// registrationTags returns the miner tags extended with the values the agent
+// needs after registration: the agent version it should be running and its
+// effective configuration profile. These are never stored on the miner.
+func (s *Server) registrationTags(ctx context.Context, logger *logrus.Entry, miner *datastore.Miner) map[string]string {
+	tags := map[string]string{}
+	for k, v := range miner.Tags {
+		tags[k] = v
+	}
+
+	targetVersion, err := s.ds.Rollouts.TargetVersion(ctx, miner)
+	if err != nil {
+		logger.Errorf("failed to get target version: %s", err)
+	} else if targetVersion != "" {
+		tags[ext.TagTargetVersion] = targetVersion
+	}
+
+	profile, err := s.ds.Profiles.Effective(ctx, miner)
+	if err != nil {
+		logger.Errorf("failed to get config profile: %s", err)
+	} else if profile != nil {
+		b, err := json.Marshal(toConfigProfile(profile))
+		if err != nil {
+			logger.Errorf("failed to marshal config profile: %s", err)
+		} else {
+			tags[ext.TagConfigProfile] = string(b)
+		}
+	}
+
+	b, err := json.Marshal(s.candidates.Eligibility())
+	if err != nil {
+		logger.Errorf("failed to marshal eligibility policy: %s", err)
+	} else {
+		tags[ext.TagEligibilityPolicy] = string(b)
+	}
+
+	return tags
+}
+
 func (s *Server) Ping(ctx context.Context, req *v1.PingRequest) (*v1.PingResponse, error) {
 	span := opentracing.SpanFromContext(ctx)
 	span.SetTag("client_id", req.ClientID)
@@ -129,60 +206,40 @@ func (s *Server) Ping(ctx context.Context, req *v1.PingRequest) (*v1.PingRespons
 		return nil, err
 	}
 
-	if err := s.ds.Miners.UpdateLastPingAt(ctx, miner); err != nil {
+	if err := s.ds.Miners.UpdateLastPingAt(ctx, miner, s.liveness); err != nil {
 		s.logger.Errorf("failed to update last ping at: %s", err)
 		return nil, err
 	}
 
-	go func(logger *logrus.Entry, req *v1.PingRequest) {
-		sysInfo := map[string]interface{}{}
-		if err := json.Unmarshal(req.SystemInfo, &sysInfo); err != nil {
-			logger.Errorf("failed to unmarshal system info: %s", err)
-		} else {
-			if ip, ok := sysInfo["ip"].(string); ok {
-				latitude, longitude, err := GetLatLon(ip)
-				if err != nil {
-					logger.WithField("ip", ip).Errorf("failed to get location by ip: %s", err)
-				} else {
-					geoInfo := map[string]interface{}{
-						"latitude":  latitude,
-						"longitude": longitude,
-					}
-
-					if err := s.ds.Miners.UpdateGeolocation(ctx, miner, geoInfo); err != nil {
-						logger.Errorf("failed to update geolocation: %s", err)
-					}
-
-					sysInfo["geo"] = geoInfo
-				}
-			}
-
-			if err := s.ds.Miners.UpdateSystemInfo(ctx, miner, sysInfo); err != nil {
-				logger.Errorf("failed to update system info: %s", err)
-			}
-		}
+	if err := s.exchangePing(ctx, s.logger.WithField("miner_id", miner.ID), miner); err != nil {
+		s.logger.Errorf("failed to exchange ping: %s", err)
+		return nil, err
+	}
 
-		if req.CapacityInfo != nil && len(req.CapacityInfo) > 0 {
-			capacityInfo := map[string]interface{}{}
-			if err := json.Unmarshal(req.CapacityInfo, &capacityInfo); err != nil {
-				logger.Errorf("failed to unmarshal capacity info: %s", err)
-			}
+	// The request context is cancelled once the response is sent, the
+	// processing below runs on its own and Stop waits for it.
+	ctx = opentracing.ContextWithSpan(context.Background(), span)
 
-			if err := s.ds.Miners.UpdateCapacityInfo(ctx, miner, capacityInfo); err != nil {
-				logger.Errorf("failed to update capacity info: %s", err)
-			}
-		}
+	s.pings.Add(1)
+	go func(logger *logrus.Entry, req *v1.PingRequest) {
+		defer s.pings.Done()
+		s.recordTelemetry(ctx, logger, miner, req.SystemInfo, req.CapacityInfo)
 	}(s.logger, req)
 
 	return &v1.PingResponse{}, nil
 }
 
 func (s *Server) GetForceTaskList(ctx context.Context, req *protoempty.Empty) (*v1.ForceTaskListResponse, error) {
-	ids, err := s.ds.Miners.GetForceTaskIDs(ctx)
+	pins, err := s.ds.Pins.ListActive(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	ids := []string{}
+	for _, pin := range pins {
+		ids = append(ids, pin.TaskID)
+	}
+
 	return &v1.ForceTaskListResponse{Ids: ids}, nil
 }
 
@@ -229,11 +286,24 @@ func (s *Server) AssignTask(ctx context.Context, req *v1.AssignTaskRequest) (*pr
 		return nil, err
 	}
 
-	if err := s.ds.Miners.UpdateCurrentTask(ctx, miner, req.TaskID, false); err != nil {
-		s.logger.Errorf("failed to update current task: %s", err)
+	if miner.IsReserved() && miner.ReservedTaskID.String != req.TaskID {
+		return nil, status.Errorf(codes.FailedPrecondition, "miner is reserved for another task")
+	}
+
+	if err := s.ds.Miners.AssignTask(ctx, miner, req.TaskID); err != nil {
+		if err == datastore.ErrMinerNotAvailable {
+			return nil, status.Errorf(codes.FailedPrecondition, "miner has no free task slots")
+		}
+		s.logger.Errorf("failed to assign task: %s", err)
 		return nil, err
 	}
 
+	// The reservation of the miner became the assignment, other miners
+	// reserved for the task are not needed anymore.
+	if _, err := s.ds.Miners.ReleaseReservations(ctx, req.TaskID); err != nil {
+		s.logger.Errorf("failed to release reservations: %s", err)
+	}
+
 	return &protoempty.Empty{}, nil
 }
 
@@ -247,6 +317,13 @@ func (s *Server) UnassignTask(ctx context.Context, req *v1.AssignTaskRequest) (*
 	})
 	logger.Info("unassigning task")
 
+	if req.TaskID != "" {
+		if _, err := s.ds.Miners.ReleaseReservations(ctx, req.TaskID); err != nil {
+			logger.Errorf("failed to release reservations: %s", err)
+			return nil, err
+		}
+	}
+
 	if req.ClientID != "" {
 		miner, err := s.ds.Miners.Get(ctx, req.ClientID, "")
 		if err != nil {
@@ -254,20 +331,36 @@ func (s *Server) UnassignTask(ctx context.Context, req *v1.AssignTaskRequest) (*
 			return nil, err
 		}
 
-		if err := s.ds.Miners.UpdateCurrentTask(ctx, miner, "", true); err != nil {
-			logger.Errorf("failed to update current task: %s", err)
+		if err := s.ds.Miners.UnassignTask(ctx, miner, req.TaskID); err != nil {
+			logger.Errorf("failed to unassign task: %s", err)
+			return nil, err
+		}
+
+		if err := s.ds.Pins.CancelByMiner(ctx, miner.ID, req.TaskID); err != nil {
+			logger.Errorf("failed to cancel pins: %s", err)
 			return nil, err
 		}
 	} else {
 		if req.TaskID != "" {
-			miners, err := s.ds.Miners.ListByTag(ctx, "force_task_id", req.TaskID)
+			pins, err := s.ds.Pins.ListActiveByTask(ctx, req.TaskID)
 			if err != nil {
-				logger.Errorf("failed to list miners by tag: %s", err)
+				logger.Errorf("failed to list pins by task: %s", err)
 				return nil, err
 			}
-			for _, miner := range miners {
-				if err := s.ds.Miners.UpdateCurrentTask(ctx, miner, "", true); err != nil {
-					logger.Errorf("failed to update current task: %s", err)
+			for _, pin := range pins {
+				miner, err := s.ds.Miners.Get(ctx, pin.MinerID, "")
+				if err != nil {
+					logger.Errorf("failed to get miner: %s", err)
+					return nil, err
+				}
+
+				if err := s.ds.Miners.UnassignTask(ctx, miner, req.TaskID); err != nil {
+					logger.Errorf("failed to unassign task: %s", err)
+					return nil, err
+				}
+
+				if err := s.ds.Pins.Cancel(ctx, pin); err != nil {
+					logger.Errorf("failed to cancel pin: %s", err)
 					return nil, err
 				}
 			}
@@ -282,20 +375,16 @@ func (s *Server) GetMinersWithForceTask(ctx context.Context, req *protoempty.Emp
 		Items: []*v1.MinerWithForceTaskResponse{},
 	}
 
-	miners, err := s.ds.Miners.List(ctx, nil)
+	pins, err := s.ds.Pins.ListActive(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, miner := range miners {
-		if ft, ok := miner.Tags["force_task_id"]; ok {
-			if len(ft) > 0 {
-				resp.Items = append(resp.Items, &v1.MinerWithForceTaskResponse{
-					Id:     miner.ID,
-					TaskId: ft,
-				})
-			}
-		}
+	for _, pin := range pins {
+		resp.Items = append(resp.Items, &v1.MinerWithForceTaskResponse{
+			Id:     pin.MinerID,
+			TaskId: pin.TaskID,
+		})
 	}
 
 	return resp, nil
@@ -306,24 +395,64 @@ func (s *Server) GetMinersCandidates(ctx context.Context, req *v1.MinersCandidat
 	span.SetTag("encode_capacity", req.EncodeCapacity)
 	span.SetTag("cpu_capacity", req.CpuCapacity)
 
-	miners, err := s.ds.Miners.ListCandidates(ctx, req.EncodeCapacity, req.CpuCapacity)
+	candidatesReq := &candidates.Request{
+		EncodeCapacity: req.EncodeCapacity,
+		CpuCapacity:    req.CpuCapacity,
+	}
+
+	reservation, err := candidatesRequestFromContext(ctx, candidatesReq)
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var items []*candidates.Candidate
+	if reservation != nil {
+		span.SetTag("task_id", reservation.TaskID)
+
+		ttl := s.reservationTTL
+		if reservation.TTLSeconds > 0 {
+			ttl = time.Duration(reservation.TTLSeconds) * time.Second
+		}
+
+		c, err := s.candidates.Reserve(ctx, candidatesReq, reservation.TaskID, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			items = append(items, c)
+		}
+	} else {
+		items, err = s.candidates.Candidates(ctx, candidatesReq)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	resp := &v1.MinersCandidatesResponse{
 		Items: []*v1.MinerCandidateResponse{},
 	}
+	scores := &ext.CandidatesResponse{
+		Items: []*ext.CandidateScore{},
+	}
 
-	for _, miner := range miners {
-		m := toMinerResponse(miner)
+	for _, c := range items {
 		resp.Items = append(resp.Items, &v1.MinerCandidateResponse{
-			ID:         miner.ID,
-			Stake:      m.TotalStake,
-			IsInternal: miner.IsInternal,
+			ID:         c.Miner.ID,
+			Stake:      c.Miner.TotalStake(),
+			IsInternal: c.Miner.IsInternal,
+		})
+		scores.Items = append(scores.Items, &ext.CandidateScore{
+			ID:            c.Miner.ID,
+			Score:         c.Score,
+			Factors:       c.Scores,
+			ReservedUntil: c.Miner.ReservedUntil,
 		})
 	}
 
+	if err := ext.SetHeader(ctx, ext.KeyCandidates, scores); err != nil {
+		s.logger.WithError(err).Error("failed to send candidate scores")
+	}
+
 	return resp, nil
 }
 
@@ -344,22 +473,97 @@ func (s *Server) GetKey(ctx context.Context, req *v1.KeyRequest) (*v1.KeyRespons
 	}, nil
 }
 
+// updateLease renews or releases the lease the holder has on an internal
+// miner.
+func (s *Server) updateLease(ctx context.Context, req *ext.InternalMinerRequest, holder string, ttl time.Duration) (*datastore.Miner, error) {
+	miner, err := s.ds.Miners.Get(ctx, req.MinerID, "")
+	if err != nil {
+		if err == datastore.ErrMinerNotFound {
+			return nil, rpc.ErrRpcNotFound
+		}
+		return nil, err
+	}
+
+	if !miner.IsInternal || !miner.IsLeased() || miner.LeaseHolder.String != holder {
+		return nil, status.Errorf(codes.FailedPrecondition, "miner is not leased to %s", holder)
+	}
+
+	switch req.Action {
+	case ext.LeaseActionRenew:
+		err = s.ds.Miners.RenewLease(ctx, miner, holder, ttl)
+		if err == datastore.ErrMinerNotAvailable {
+			return nil, status.Errorf(codes.FailedPrecondition, "miner is not leased to %s", holder)
+		}
+	case ext.LeaseActionRelease:
+		err = s.ds.Miners.ReleaseLease(ctx, miner)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown lease action %q", req.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return miner, nil
+}
+
 func (s *Server) GetInternalMiner(ctx context.Context, req *v1.InternalMinerRequest) (*v1.InternalMinerResponse, error) {
-	miner, err := s.ds.Miners.GetInternal(ctx)
+	leaseReq := &ext.InternalMinerRequest{}
+	if _, err := ext.FromIncomingContext(ctx, ext.KeyInternalMiner, leaseReq); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// The default holder is the caller host without the port, which changes
+	// on every connection.
+	holder := leaseReq.Holder
+	if holder == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			holder = p.Addr.String()
+			if host, _, err := net.SplitHostPort(holder); err == nil {
+				holder = host
+			}
+		}
+	}
+
+	ttl := s.internalLeaseTTL
+	if leaseReq.TTLSeconds > 0 {
+		ttl = time.Duration(leaseReq.TTLSeconds) * time.Second
+	}
+
+	var (
+		miner *datastore.Miner
+		err   error
+	)
+	if leaseReq.Action != "" {
+		miner, err = s.updateLease(ctx, leaseReq, holder, ttl)
+	} else {
+		miner, err = s.ds.Miners.GetInternal(ctx, holder, ttl)
+	}
 	if err != nil {
 		s.logger.WithError(err).Error("failed to get internal miner")
 		return nil, err
 	}
 
+	err = ext.SetHeader(ctx, ext.KeyInternalMiner, &ext.InternalMinerResponse{
+		Holder:         holder,
+		LeaseExpiresAt: miner.LeaseExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	resp := &v1.InternalMinerResponse{
 		ID:     miner.ID,
 		Key:    miner.Key.String,
 		Secret: miner.Secret.String,
 	}
-	if ft, ok := miner.Tags["force_task_id"]; ok {
-		if len(ft) > 0 {
-			resp.TaskID = ft
-		}
+
+	pin, err := s.ds.Pins.GetActiveByMiner(ctx, miner.ID)
+	if err != nil && err != datastore.ErrPinNotFound {
+		s.logger.WithError(err).Error("failed to get miner pin")
+		return nil, err
+	}
+	if pin != nil {
+		resp.TaskID = pin.TaskID
 	}
 
 	return resp, nil
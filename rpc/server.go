@@ -1,10 +1,14 @@
 package rpc
 
 import (
+	"context"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	v1 "github.com/videocoin/cloud-api/miners/v1"
+	"github.com/videocoin/cloud-miners/candidates"
 	"github.com/videocoin/cloud-miners/datastore"
 	"github.com/videocoin/cloud-pkg/grpcutil"
 	"github.com/videocoin/cloud-pkg/iam"
@@ -14,22 +18,46 @@ import (
 	"google.golang.org/grpc/reflection"
 )
 
+// ServiceName is the name the miners service is registered under in the
+// gRPC health service.
+const ServiceName = "cloud.api.miners.v1.MinersService"
+
 type ServerOption struct {
-	Logger          *logrus.Entry
-	Addr            string
-	DBURI           string
-	AuthTokenSecret string
-	IAM             *iam.Client
+	Logger             *logrus.Entry
+	Addr               string
+	DBURI              string
+	AuthTokenSecret    string
+	AdminUserIDs       []string
+	IAM                *iam.Client
+	VersionPolicy      *VersionPolicy
+	Candidates         *candidates.Engine
+	InternalLeaseTTL   time.Duration
+	ReservationTTL     time.Duration
+	BenchmarkTolerance float64
+	Liveness           datastore.Liveness
 }
 
 type Server struct {
-	logger          *logrus.Entry
-	addr            string
-	authTokenSecret string
-	grpc            *grpc.Server
-	listen          net.Listener
-	ds              *datastore.Datastore
-	iam             *iam.Client
+	logger             *logrus.Entry
+	addr               string
+	authTokenSecret    string
+	adminUserIDs       []string
+	grpc               *grpc.Server
+	listen             net.Listener
+	ds                 *datastore.Datastore
+	iam                *iam.Client
+	versionPolicy      *VersionPolicy
+	candidates         *candidates.Engine
+	internalLeaseTTL   time.Duration
+	reservationTTL     time.Duration
+	benchmarkTolerance float64
+	liveness           datastore.Liveness
+	health             *health.Server
+	pings              sync.WaitGroup
+
+	sessionsMutex  sync.Mutex
+	sessions       map[string]*session
+	sessionsClosed bool
 }
 
 func NewServer(opts *ServerOption, ds *datastore.Datastore) (*Server, error) {
@@ -38,6 +66,7 @@ func NewServer(opts *ServerOption, ds *datastore.Datastore) (*Server, error) {
 
 	healthService := health.NewServer()
 	healthv1.RegisterHealthServer(grpcServer, healthService)
+	healthService.SetServingStatus(ServiceName, healthv1.HealthCheckResponse_NOT_SERVING)
 
 	listen, err := net.Listen("tcp", opts.Addr)
 	if err != nil {
@@ -45,16 +74,26 @@ func NewServer(opts *ServerOption, ds *datastore.Datastore) (*Server, error) {
 	}
 
 	rpcServer := &Server{
-		logger:          opts.Logger,
-		addr:            opts.Addr,
-		authTokenSecret: opts.AuthTokenSecret,
-		iam:             opts.IAM,
-		grpc:            grpcServer,
-		listen:          listen,
-		ds:              ds,
+		logger:             opts.Logger,
+		addr:               opts.Addr,
+		authTokenSecret:    opts.AuthTokenSecret,
+		adminUserIDs:       opts.AdminUserIDs,
+		iam:                opts.IAM,
+		versionPolicy:      opts.VersionPolicy,
+		candidates:         opts.Candidates,
+		internalLeaseTTL:   opts.InternalLeaseTTL,
+		reservationTTL:     opts.ReservationTTL,
+		benchmarkTolerance: opts.BenchmarkTolerance,
+		liveness:           opts.Liveness,
+		health:             healthService,
+		grpc:               grpcServer,
+		listen:             listen,
+		ds:                 ds,
+		sessions:           map[string]*session{},
 	}
 
 	v1.RegisterMinersServiceServer(grpcServer, rpcServer)
+	grpcServer.RegisterService(&sessionServiceDesc, rpcServer)
 	reflection.Register(grpcServer)
 
 	return rpcServer, nil
@@ -64,3 +103,43 @@ func (s *Server) Start() error {
 	s.logger.Infof("starting rpc server on %s", s.addr)
 	return s.grpc.Serve(s.listen)
 }
+
+// Health returns the gRPC health service so its status can follow the
+// dependency checks.
+func (s *Server) Health() *health.Server {
+	return s.health
+}
+
+// Stop reports NOT_SERVING to health checks, closes the session streams,
+// lets in-flight calls finish until the context is done and waits for
+// background ping processing.
+func (s *Server) Stop(ctx context.Context) error {
+	s.health.Shutdown()
+	s.closeSessions()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.logger.Warning("graceful stop timed out, closing connections")
+		s.grpc.Stop()
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		s.pings.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,280 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/sirupsen/logrus"
+	"github.com/videocoin/cloud-api/rpc"
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sessionInterval is how often an open session counts as a ping and looks
+// for news to push to the agent. It stays below the offline timeout of the
+// manager.
+const sessionInterval = time.Second * 5
+
+var (
+	errSessionReplaced = status.Error(codes.Aborted, "session is replaced by a newer one")
+	errSessionsClosed  = status.Error(codes.Unavailable, "server is shutting down")
+)
+
+var sessionServiceDesc = grpc.ServiceDesc{
+	ServiceName: ext.SessionServiceName,
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Session",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Server).Session(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+// session is an open session stream, closed with err when a newer session of
+// the same miner opens or the server stops.
+type session struct {
+	cancel context.CancelFunc
+	err    error
+}
+
+// sessionState is what the agent was last told, so that only changes are
+// pushed.
+type sessionState struct {
+	task           *string
+	drain          *bool
+	targetVersion  string
+	configProfile  string
+	configRevision int
+}
+
+// Session serves the session stream of an agent, see ext.SessionServiceName.
+// While it is open the miner counts as pinging every sessionInterval, so its
+// liveness follows the stream. Once the stream closes the miner goes offline
+// after the ping timeout, like an agent that stopped pinging. Agents without
+// the stream keep using Ping.
+func (s *Server) Session(stream grpc.ServerStream) error {
+	hello := &ext.AgentMessage{}
+	if err := stream.RecvMsg(hello); err != nil {
+		return err
+	}
+	if hello.Hello == nil || hello.Hello.ClientID == "" {
+		return status.Error(codes.InvalidArgument, "session must start with hello")
+	}
+
+	logger := s.logger.WithField("client_id", hello.Hello.ClientID)
+
+	miner, err := s.ds.Miners.Get(stream.Context(), hello.Hello.ClientID, "")
+	if err != nil {
+		if err == datastore.ErrMinerNotFound {
+			return rpc.ErrRpcNotFound
+		}
+		logger.Errorf("failed to get miner: %s", err)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	sess, err := s.openSession(miner.ID, cancel)
+	if err != nil {
+		return err
+	}
+	defer s.closeSession(miner.ID, sess)
+
+	logger.Info("session opened")
+	defer logger.Info("session closed")
+
+	messages := make(chan *ext.AgentMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg := &ext.AgentMessage{}
+			if err := stream.RecvMsg(msg); err != nil {
+				recvErr <- err
+				return
+			}
+
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	state := &sessionState{
+		configProfile:  hello.Hello.ConfigProfile,
+		configRevision: hello.Hello.ConfigRevision,
+	}
+
+	ticker := time.NewTicker(sessionInterval)
+	defer ticker.Stop()
+
+	var msg *ext.AgentMessage
+	for {
+		if err := s.exchangeSession(ctx, logger, stream, miner.ID, state, msg); err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("failed to exchange session: %s", err)
+			}
+			return err
+		}
+
+		msg = nil
+		select {
+		case <-ctx.Done():
+			if err := s.sessionErr(sess); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case err := <-recvErr:
+			return err
+		case msg = <-messages:
+		case <-ticker.C:
+		}
+	}
+}
+
+// exchangeSession records a ping, stores what the agent sent, if anything,
+// and pushes what changed for the agent since the last exchange.
+func (s *Server) exchangeSession(ctx context.Context, logger *logrus.Entry, stream grpc.ServerStream, minerID string, state *sessionState, msg *ext.AgentMessage) error {
+	miner, err := s.ds.Miners.Get(ctx, minerID, "")
+	if err != nil {
+		return err
+	}
+
+	if miner.IsBlock {
+		block, err := s.ds.Miners.GetActiveBlock(ctx, miner)
+		if err != nil {
+			return err
+		}
+		return blockedError(block)
+	}
+
+	if err := s.ds.Miners.UpdateLastPingAt(ctx, miner, s.liveness); err != nil {
+		return err
+	}
+
+	resp := &ext.SessionMessage{}
+
+	if msg != nil {
+		if msg.Heartbeat != nil {
+			s.recordTelemetry(ctx, logger, miner, msg.Heartbeat.SystemInfo, msg.Heartbeat.CapacityInfo)
+		}
+
+		for _, result := range msg.Results {
+			s.ackCommand(ctx, logger.WithField("command_id", result.ID), miner, result)
+			resp.Acks = append(resp.Acks, result.ID)
+		}
+	}
+
+	commands, err := s.ds.Commands.Deliver(ctx, miner.ID)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		resp.Commands = append(resp.Commands, toCommand(command))
+	}
+
+	targetVersion, err := s.ds.Rollouts.TargetVersion(ctx, miner)
+	if err != nil {
+		return err
+	}
+
+	profile, err := s.ds.Profiles.Effective(ctx, miner)
+	if err != nil {
+		return err
+	}
+
+	state.update(resp, miner, targetVersion, profile)
+
+	if resp.IsEmpty() {
+		return nil
+	}
+
+	return stream.SendMsg(resp)
+}
+
+// update adds to resp what changed for the agent since it was last told and
+// remembers it.
+func (st *sessionState) update(resp *ext.SessionMessage, miner *datastore.Miner, targetVersion string, profile *datastore.ConfigProfile) {
+	if st.task == nil || *st.task != miner.CurrentTaskID.String {
+		st.task = pointer.ToString(miner.CurrentTaskID.String)
+		resp.Task = &ext.SessionTask{ID: miner.CurrentTaskID.String}
+	}
+
+	if st.drain == nil || *st.drain != miner.IsDraining {
+		st.drain = pointer.ToBool(miner.IsDraining)
+		resp.Drain = pointer.ToBool(miner.IsDraining)
+	}
+
+	if targetVersion != st.targetVersion {
+		st.targetVersion = targetVersion
+		if targetVersion != miner.Version.String {
+			resp.TargetVersion = targetVersion
+		}
+	}
+
+	if profile != nil && (profile.Name != st.configProfile || profile.Revision != st.configRevision) {
+		st.configProfile = profile.Name
+		st.configRevision = profile.Revision
+		resp.ConfigProfile = toConfigProfile(profile)
+	}
+}
+
+// openSession registers the session of the miner, closing the one it had.
+func (s *Server) openSession(minerID string, cancel context.CancelFunc) (*session, error) {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	if s.sessionsClosed {
+		return nil, errSessionsClosed
+	}
+
+	if prev, ok := s.sessions[minerID]; ok {
+		prev.err = errSessionReplaced
+		prev.cancel()
+	}
+
+	sess := &session{cancel: cancel}
+	s.sessions[minerID] = sess
+
+	return sess, nil
+}
+
+func (s *Server) closeSession(minerID string, sess *session) {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	if s.sessions[minerID] == sess {
+		delete(s.sessions, minerID)
+	}
+}
+
+func (s *Server) sessionErr(sess *session) error {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	return sess.err
+}
+
+// closeSessions closes every open session and refuses new ones, so agents
+// reconnect to another replica.
+func (s *Server) closeSessions() {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	s.sessionsClosed = true
+	for _, sess := range s.sessions {
+		sess.err = errSessionsClosed
+		sess.cancel()
+	}
+}
@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/AlekSi/pointer"
+	"github.com/mailru/dbr"
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSessionStateUpdate(t *testing.T) {
+	profile := &datastore.ConfigProfile{Name: "default", Revision: 2}
+
+	// The steps run in order on the same state, like the exchanges of a
+	// session.
+	steps := []struct {
+		name          string
+		miner         *datastore.Miner
+		targetVersion string
+		profile       *datastore.ConfigProfile
+		wantTask      *string
+		wantDrain     *bool
+		wantVersion   string
+		wantProfile   bool
+	}{
+		{
+			name:        "first exchange sends the full state",
+			miner:       &datastore.Miner{Version: dbr.NewNullString("1.0.0")},
+			wantTask:    pointer.ToString(""),
+			wantDrain:   pointer.ToBool(false),
+			wantProfile: false,
+		},
+		{
+			name:  "nothing changed",
+			miner: &datastore.Miner{Version: dbr.NewNullString("1.0.0")},
+		},
+		{
+			name:     "task assigned",
+			miner:    &datastore.Miner{Version: dbr.NewNullString("1.0.0"), CurrentTaskID: dbr.NewNullString("task")},
+			wantTask: pointer.ToString("task"),
+		},
+		{
+			name:      "draining",
+			miner:     &datastore.Miner{Version: dbr.NewNullString("1.0.0"), CurrentTaskID: dbr.NewNullString("task"), IsDraining: true},
+			wantDrain: pointer.ToBool(true),
+		},
+		{
+			name:          "rollout and profile change",
+			miner:         &datastore.Miner{Version: dbr.NewNullString("1.0.0"), CurrentTaskID: dbr.NewNullString("task"), IsDraining: true},
+			targetVersion: "1.1.0",
+			profile:       profile,
+			wantVersion:   "1.1.0",
+			wantProfile:   true,
+		},
+		{
+			name:          "rollout and profile sent once",
+			miner:         &datastore.Miner{Version: dbr.NewNullString("1.0.0"), CurrentTaskID: dbr.NewNullString("task"), IsDraining: true},
+			targetVersion: "1.1.0",
+			profile:       profile,
+		},
+		{
+			name:          "task unassigned and drain over",
+			miner:         &datastore.Miner{Version: dbr.NewNullString("1.1.0")},
+			targetVersion: "1.1.0",
+			profile:       profile,
+			wantTask:      pointer.ToString(""),
+			wantDrain:     pointer.ToBool(false),
+		},
+	}
+
+	state := &sessionState{configProfile: "default", configRevision: 1}
+
+	for _, step := range steps {
+		resp := &ext.SessionMessage{}
+		state.update(resp, step.miner, step.targetVersion, step.profile)
+
+		switch {
+		case step.wantTask == nil && resp.Task != nil:
+			t.Errorf("%s: task = %q, want none", step.name, resp.Task.ID)
+		case step.wantTask != nil && (resp.Task == nil || resp.Task.ID != *step.wantTask):
+			t.Errorf("%s: task = %v, want %q", step.name, resp.Task, *step.wantTask)
+		}
+
+		switch {
+		case step.wantDrain == nil && resp.Drain != nil:
+			t.Errorf("%s: drain = %v, want none", step.name, *resp.Drain)
+		case step.wantDrain != nil && (resp.Drain == nil || *resp.Drain != *step.wantDrain):
+			t.Errorf("%s: drain = %v, want %v", step.name, resp.Drain, *step.wantDrain)
+		}
+
+		if resp.TargetVersion != step.wantVersion {
+			t.Errorf("%s: target version = %q, want %q", step.name, resp.TargetVersion, step.wantVersion)
+		}
+
+		if got := resp.ConfigProfile != nil; got != step.wantProfile {
+			t.Errorf("%s: config profile sent = %v, want %v", step.name, got, step.wantProfile)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := &Server{sessions: map[string]*session{}}
+
+	_, cancelFirst := context.WithCancel(context.Background())
+	first, err := s.openSession("miner", cancelFirst)
+	if err != nil {
+		t.Fatalf("failed to open session: %s", err)
+	}
+
+	_, cancelSecond := context.WithCancel(context.Background())
+	second, err := s.openSession("miner", cancelSecond)
+	if err != nil {
+		t.Fatalf("failed to open session: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		session *session
+		want    error
+	}{
+		{"replaced session", first, errSessionReplaced},
+		{"current session", second, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.sessionErr(tt.session); got != tt.want {
+				t.Errorf("sessionErr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Closing the replaced session keeps the current one.
+	s.closeSession("miner", first)
+	if s.sessions["miner"] != second {
+		t.Fatal("closing a replaced session dropped the current one")
+	}
+
+	s.closeSessions()
+	if got := s.sessionErr(second); got != errSessionsClosed {
+		t.Errorf("sessionErr() after closing = %v, want %v", got, errSessionsClosed)
+	}
+	if _, err := s.openSession("other", func() {}); err != errSessionsClosed {
+		t.Errorf("openSession() after closing = %v, want %v", err, errSessionsClosed)
+	}
+}
+
+func TestSessionHello(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&sessionServiceDesc, &Server{sessions: map[string]*session{}})
+	go grpcServer.Serve(listen)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listen.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name string
+		msg  *ext.AgentMessage
+	}{
+		{"no hello", &ext.AgentMessage{Heartbeat: &ext.Heartbeat{}}},
+		{"no client id", &ext.AgentMessage{Hello: &ext.SessionHello{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := conn.NewStream(context.Background(), &sessionServiceDesc.Streams[0], ext.SessionMethod, grpc.CallContentSubtype(ext.CodecName))
+			if err != nil {
+				t.Fatalf("failed to open stream: %s", err)
+			}
+
+			if err := stream.SendMsg(tt.msg); err != nil {
+				t.Fatalf("failed to send: %s", err)
+			}
+
+			err = stream.RecvMsg(&ext.SessionMessage{})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("RecvMsg() error = %v, want %s", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
@@ -2,17 +2,25 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"math"
+	"strconv"
+	"time"
 
+	"github.com/AlekSi/pointer"
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	"github.com/opentracing/opentracing-go"
 	emitterv1 "github.com/videocoin/cloud-api/emitter/v1"
 	v1 "github.com/videocoin/cloud-api/miners/v1"
 	"github.com/videocoin/cloud-api/rpc"
 	usersv1 "github.com/videocoin/cloud-api/users/v1"
+	"github.com/videocoin/cloud-miners/candidates"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/ext"
 	"github.com/videocoin/cloud-pkg/auth"
-	"github.com/videocoin/cloud-pkg/ethutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (s *Server) authToken(ctx context.Context) (string, error) {
@@ -44,6 +52,23 @@ func (s *Server) authenticate(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
+// AuthenticateAdmin authenticates the caller like the user calls and only
+// lets the configured admins through.
+func (s *Server) AuthenticateAdmin(ctx context.Context) (string, error) {
+	userID, err := s.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range s.adminUserIDs {
+		if id == userID {
+			return userID, nil
+		}
+	}
+
+	return "", rpc.ErrRpcPermissionDenied
+}
+
 func (s *Server) getTokenType(ctx context.Context) auth.TokenType {
 	tokenType, ok := auth.TypeFromContext(ctx)
 	if !ok {
@@ -53,6 +78,70 @@ func (s *Server) getTokenType(ctx context.Context) auth.TokenType {
 	return tokenType
 }
 
+func blockedError(block *datastore.Block) error {
+	msg := "miner is blocked"
+	if block != nil {
+		if block.Reason.String != "" {
+			msg += ": " + block.Reason.String
+		}
+		if block.ExpiresAt != nil {
+			msg += " (until " + block.ExpiresAt.UTC().Format(time.RFC3339) + ")"
+		}
+	}
+
+	return status.Error(codes.PermissionDenied, msg)
+}
+
+// addOwnerTags adds what only the owner of the miner gets to see: what its
+// reputation is made of, its latest incidents and commands, its version
+// history and upcoming maintenance windows. Empty values are left out.
+func (s *Server) addOwnerTags(ctx context.Context, miner *datastore.Miner, tags map[string]string) error {
+	incidents, err := s.ds.Incidents.List(ctx, miner.ID, &datastore.ListFilter{Limit: pointer.ToInt(maxOwnerIncidents)})
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.ds.Miners.ListVersionHistory(ctx, miner.ID)
+	if err != nil {
+		return err
+	}
+
+	windows, err := s.listMaintenanceWindows(ctx, miner)
+	if err != nil {
+		return err
+	}
+
+	commands, err := s.listOwnerCommands(ctx, miner)
+	if err != nil {
+		return err
+	}
+
+	values := []struct {
+		key   string
+		value interface{}
+		empty bool
+	}{
+		{ext.TagReputationComponents, miner.Reputation, miner.Reputation.UpdatedAt == nil},
+		{ext.TagIncidents, incidents, len(incidents) == 0},
+		{ext.TagVersionHistory, versions, len(versions) == 0},
+		{ext.TagMaintenance, windows, len(windows) == 0},
+		{ext.TagCommands, commands, len(commands) == 0},
+	}
+
+	for _, v := range values {
+		if v.empty {
+			continue
+		}
+		b, err := json.Marshal(v.value)
+		if err != nil {
+			return err
+		}
+		tags[v.key] = string(b)
+	}
+
+	return nil
+}
+
 func toMinerResponse(miner *datastore.Miner) *v1.MinerResponse {
 	systemInfo := &v1.SystemInfo{}
 
@@ -91,55 +180,43 @@ func toMinerResponse(miner *datastore.Miner) *v1.MinerResponse {
 		capacityInfo.Cpu = value.(float64)
 	}
 
-	var totalStake, delegatedStake, selfStake float64
-	if miner.WorkerInfo != nil {
-		if miner.WorkerInfo.TotalStake != "" {
-			wei, err := ethutils.ParseBigInt(miner.WorkerInfo.TotalStake)
-			if err == nil {
-				vid, e := ethutils.WeiToEth(&wei)
-				if e == nil {
-					totalStake, _ = vid.Float64()
-				}
-			}
-		}
-
-		if miner.WorkerInfo.SelfStake != "" {
-			wei, err := ethutils.ParseBigInt(miner.WorkerInfo.SelfStake)
-			if err == nil {
-				vid, e := ethutils.WeiToEth(&wei)
-				if e == nil {
-					selfStake, _ = vid.Float64()
-				}
-			}
-		}
-
-		if miner.WorkerInfo.DelegatedStake != "" {
-			wei, err := ethutils.ParseBigInt(miner.WorkerInfo.DelegatedStake)
-			if err == nil {
-				vid, e := ethutils.WeiToEth(&wei)
-				if e == nil {
-					delegatedStake, _ = vid.Float64()
-				}
-			}
-		}
-	}
-
 	workerState := emitterv1.WorkerStateBonding
 	if miner.WorkerInfo != nil {
 		workerState = miner.WorkerInfo.State
 	}
 
+	// Miners outside of their availability schedule keep their status and
+	// are told apart from offline ones by the availability tag.
+	tags := map[string]string{
+		ext.TagReputation: strconv.FormatFloat(miner.Reputation.Score(), 'f', 2, 64),
+	}
+	if miner.IsScheduledOff() {
+		tags[ext.TagAvailability] = ext.AvailabilityScheduledOff
+	}
+	if miner.IsUnstable {
+		tags[ext.TagStability] = "unstable"
+	}
+	if miner.Version.String != "" {
+		tags[ext.TagVersion] = miner.Version.String
+	}
+	if miner.IsReadyForMaintenance() {
+		tags[ext.TagDrain] = ext.DrainDrained
+	} else if miner.IsDraining {
+		tags[ext.TagDrain] = ext.DrainDraining
+	}
+
 	return &v1.MinerResponse{
 		Id:                       miner.ID,
+		Tags:                     tags,
 		Name:                     miner.Name,
 		Status:                   miner.Status,
 		SystemInfo:               systemInfo,
 		CapacityInfo:             capacityInfo,
 		UserID:                   miner.UserID,
 		Address:                  miner.Address.String,
-		TotalStake:               totalStake,
-		DelegatedStake:           delegatedStake,
-		SelfStake:                selfStake,
+		TotalStake:               miner.TotalStake(),
+		DelegatedStake:           miner.DelegatedStake(),
+		SelfStake:                miner.SelfStake(),
 		Reward:                   miner.Reward,
 		IsBlock:                  miner.IsBlock,
 		IsInternal:               miner.IsInternal,
@@ -151,3 +228,26 @@ func toMinerResponse(miner *datastore.Miner) *v1.MinerResponse {
 		DelegatePolicy:           miner.DelegatePolicy.String,
 	}
 }
+
+// candidatesRequestFromContext reads the ext.KeyCandidates document of the
+// call into the engine request and returns the reservation it asks for.
+func candidatesRequestFromContext(ctx context.Context, req *candidates.Request) (*ext.Reservation, error) {
+	extReq := &ext.CandidatesRequest{}
+	ok, err := ext.FromIncomingContext(ctx, ext.KeyCandidates, extReq)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	req.Limit = extReq.Limit
+	req.Origin = extReq.Origin
+	req.MaxDistance = extReq.MaxDistance
+	req.PreferRadius = extReq.PreferRadius
+	req.Affinity = extReq.Affinity
+	req.Requirements = extReq.Requirements
+
+	if extReq.Reserve != nil && extReq.Reserve.TaskID == "" {
+		return nil, errors.New("reservation has no task id")
+	}
+
+	return extReq.Reserve, nil
+}
@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type VersionPolicy struct {
+	MinVersion      string
+	BlockedVersions []string
+}
+
+// Validate checks that the policy versions are well formed.
+func (p *VersionPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	for _, v := range append([]string{p.MinVersion}, p.BlockedVersions...) {
+		if v == "" {
+			continue
+		}
+		if _, err := parseVersion(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check returns a FailedPrecondition status when the agent version is below
+// the minimum, explicitly blocked or malformed, with a hint about the version
+// to upgrade to.
+func (p *VersionPolicy) Check(version string) error {
+	if p == nil {
+		return nil
+	}
+
+	if p.MinVersion == "" && len(p.BlockedVersions) == 0 {
+		return nil
+	}
+
+	if version == "" {
+		if p.MinVersion == "" {
+			return nil
+		}
+		return status.Errorf(codes.FailedPrecondition, "agent version is unknown, %s", p.upgradeHint())
+	}
+
+	for _, blocked := range p.BlockedVersions {
+		if blocked == "" {
+			continue
+		}
+		c, err := compareVersions(version, blocked)
+		if err != nil {
+			return status.Errorf(codes.FailedPrecondition, "%s, %s", err, p.upgradeHint())
+		}
+		if c == 0 {
+			return status.Errorf(codes.FailedPrecondition, "agent version %s is blocked, %s", version, p.upgradeHint())
+		}
+	}
+
+	if p.MinVersion == "" {
+		return nil
+	}
+
+	c, err := compareVersions(version, p.MinVersion)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s, %s", err, p.upgradeHint())
+	}
+	if c < 0 {
+		return status.Errorf(codes.FailedPrecondition, "agent version %s is not supported, %s", version, p.upgradeHint())
+	}
+
+	return nil
+}
+
+func (p *VersionPolicy) upgradeHint() string {
+	if p.MinVersion == "" {
+		return "please upgrade the agent"
+	}
+	return fmt.Sprintf("please upgrade the agent to %s or later", p.MinVersion)
+}
+
+// version is a semantic version: numeric major, minor and patch, missing
+// ones being zero, and the dot separated pre-release identifiers.
+type version struct {
+	core       [3]int
+	prerelease []string
+}
+
+// compareVersions compares versions by semantic versioning precedence,
+// ignoring a leading "v" and build metadata. A pre-release is lower than
+// the release it precedes, so 1.2.0-rc1 < 1.2.0.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va.core {
+		if c := compareInts(va.core[i], vb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0, nil
+	case len(va.prerelease) == 0:
+		return 1, nil
+	case len(vb.prerelease) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if c := compareIdentifiers(va.prerelease[i], vb.prerelease[i]); c != 0 {
+			return c, nil
+		}
+	}
+
+	return compareInts(len(va.prerelease), len(vb.prerelease)), nil
+}
+
+func parseVersion(s string) (*version, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+
+	result := &version{}
+
+	if i := strings.Index(v, "-"); i >= 0 {
+		result.prerelease = strings.Split(v[i+1:], ".")
+		v = v[:i]
+		for _, id := range result.prerelease {
+			if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return nil, fmt.Errorf("invalid version %q", s)
+			}
+		}
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) > len(result.core) {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		result.core[i] = n
+	}
+
+	return result, nil
+}
+
+// compareIdentifiers orders numeric identifiers numerically and below
+// alphanumeric ones, which are ordered lexically.
+func compareIdentifiers(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    int
+		wantErr bool
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.2.3+build.5", b: "1.2.3", want: 0},
+		{a: "1.10.0", b: "1.9.0", want: 1},
+		{a: "1.2.3", b: "1.2.4", want: -1},
+		{a: "2.0.0", b: "1.99.99", want: 1},
+		{a: "1.2.0-rc1", b: "1.2.0", want: -1},
+		{a: "1.2.0", b: "1.2.0-rc1", want: 1},
+		{a: "1.2.0-alpha", b: "1.2.0-alpha.1", want: -1},
+		{a: "1.2.0-alpha.1", b: "1.2.0-alpha.beta", want: -1},
+		{a: "1.2.0-beta.2", b: "1.2.0-beta.11", want: -1},
+		{a: "1.2.0-beta", b: "1.2.0-alpha", want: 1},
+		{a: "1.2.0-rc.1", b: "1.2.0-rc.1", want: 0},
+		{a: "1.2.x", b: "1.2.0", wantErr: true},
+		{a: "1.2.3.4", b: "1.2.3", wantErr: true},
+		{a: "1.2.3", b: "-1.0.0", wantErr: true},
+		{a: "1.2.0-", b: "1.2.0", wantErr: true},
+		{a: "1.2.0-rc..1", b: "1.2.0", wantErr: true},
+		{a: "1.2.0-rc_1", b: "1.2.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			got, err := compareVersions(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("compareVersions() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionPolicyCheck(t *testing.T) {
+	policy := &VersionPolicy{
+		MinVersion:      "1.2.0",
+		BlockedVersions: []string{"1.3.1"},
+	}
+
+	tests := []struct {
+		name    string
+		policy  *VersionPolicy
+		version string
+		wantErr bool
+	}{
+		{"no policy", nil, "0.1.0", false},
+		{"empty policy", &VersionPolicy{}, "garbage", false},
+		{"at the minimum", policy, "1.2.0", false},
+		{"above the minimum", policy, "v1.4.0", false},
+		{"below the minimum", policy, "1.1.9", true},
+		{"pre-release of the minimum", policy, "1.2.0-rc1", true},
+		{"blocked", policy, "1.3.1", true},
+		{"blocked with build metadata", policy, "1.3.1+abc", true},
+		{"unknown", policy, "", true},
+		{"unknown without a minimum", &VersionPolicy{BlockedVersions: []string{"1.3.1"}}, "", false},
+		{"malformed", policy, "latest", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("Check() code = %s, want %s", status.Code(err), codes.FailedPrecondition)
+			}
+		})
+	}
+}
+
+func TestVersionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *VersionPolicy
+		wantErr bool
+	}{
+		{"no policy", nil, false},
+		{"empty policy", &VersionPolicy{}, false},
+		{"well formed", &VersionPolicy{MinVersion: "v1.2.0", BlockedVersions: []string{"1.3.1-rc.2"}}, false},
+		{"malformed minimum", &VersionPolicy{MinVersion: "1.x"}, true},
+		{"malformed blocked version", &VersionPolicy{MinVersion: "1.2.0", BlockedVersions: []string{"1.3.1", "next"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
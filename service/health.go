@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/election"
+	"github.com/videocoin/cloud-miners/health"
+	"github.com/videocoin/cloud-miners/manager"
+	"github.com/videocoin/cloud-miners/rpc"
+)
+
+func newChecker(cfg *Config, ds *datastore.Datastore, srv *rpc.Server, dm *manager.Manager, el *election.Elector) (*health.Checker, error) {
+	return health.NewChecker(
+		health.WithLogger(cfg.Logger.WithField("system", "health")),
+		health.WithInterval(cfg.HealthCheckInterval),
+		health.WithElector(el),
+		health.WithGRPCHealth(srv.Health()),
+		health.WithService("", health.KindLiveness),
+		health.WithService(rpc.ServiceName, health.KindReadiness),
+		health.WithCheck(&health.Check{
+			Name:     "jobs",
+			Kind:     health.KindLiveness,
+			Critical: true,
+			Func: func(ctx context.Context) error {
+				return dm.CheckHeartbeats()
+			},
+		}),
+		health.WithCheck(&health.Check{
+			Name:     "database",
+			Kind:     health.KindReadiness,
+			Critical: true,
+			Func:     ds.Ping,
+		}),
+		health.WithCheck(&health.Check{
+			Name:     "schema",
+			Kind:     health.KindReadiness,
+			Critical: true,
+			Func: func(ctx context.Context) error {
+				expected, err := datastore.LatestMigration(cfg.MigrationsDir)
+				if err != nil {
+					return err
+				}
+
+				version, err := ds.SchemaVersion(ctx, cfg.MigrationsTable)
+				if err != nil {
+					return err
+				}
+
+				if version < expected {
+					return fmt.Errorf("schema version is %d, expected %d", version, expected)
+				}
+
+				return nil
+			},
+		}),
+		health.WithCheck(&health.Check{
+			Name: "emitter",
+			Kind: health.KindReadiness,
+			Func: func(ctx context.Context) error {
+				return dm.CheckEmitter()
+			},
+		}),
+		health.WithCheck(&health.Check{
+			Name: "geoip",
+			Kind: health.KindReadiness,
+			Func: func(ctx context.Context) error {
+				return rpc.CheckGeoIP()
+			},
+		}),
+	)
+}
@@ -1,7 +1,13 @@
 package service
 
 import (
+	"context"
+
+	"github.com/videocoin/cloud-miners/admin"
+	"github.com/videocoin/cloud-miners/candidates"
 	"github.com/videocoin/cloud-miners/datastore"
+	"github.com/videocoin/cloud-miners/election"
+	"github.com/videocoin/cloud-miners/health"
 	"github.com/videocoin/cloud-miners/manager"
 	"github.com/videocoin/cloud-miners/metrics"
 	"github.com/videocoin/cloud-miners/rpc"
@@ -15,6 +21,8 @@ type Service struct {
 	mc  *metrics.Collector
 	ms  *metrics.Server
 	dm  *manager.Manager
+	el  *election.Elector
+	hc  *health.Checker
 }
 
 func NewService(cfg *Config) (*Service, error) {
@@ -23,40 +31,109 @@ func NewService(cfg *Config) (*Service, error) {
 		return nil, err
 	}
 
-	rpcConfig := &rpc.ServerOption{
-		Logger:          cfg.Logger,
-		Addr:            cfg.Addr,
-		DBURI:           cfg.DBURI,
-		AuthTokenSecret: cfg.AuthTokenSecret,
-		IAM:             iamCli,
+	ds, err := datastore.NewDatastore(cfg.DBURI)
+	if err != nil {
+		return nil, err
 	}
 
-	ds, err := datastore.NewDatastore(cfg.DBURI)
+	ce, err := candidates.NewEngine(
+		candidates.WithLogger(cfg.Logger.WithField("system", "candidates")),
+		candidates.WithDatastore(ds),
+		candidates.WithWeights(cfg.CandidateWeights),
+		candidates.WithHardwareScores(cfg.CandidateHardwareScores),
+		candidates.WithLimit(cfg.CandidatesLimit),
+		candidates.WithEligibility(cfg.CandidateEligibility),
+	)
 	if err != nil {
 		return nil, err
 	}
 
+	versionPolicy := &rpc.VersionPolicy{
+		MinVersion:      cfg.MinAgentVersion,
+		BlockedVersions: cfg.BlockedAgentVersions,
+	}
+	if err := versionPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
+	rpcConfig := &rpc.ServerOption{
+		Logger:             cfg.Logger,
+		Addr:               cfg.Addr,
+		DBURI:              cfg.DBURI,
+		AuthTokenSecret:    cfg.AuthTokenSecret,
+		AdminUserIDs:       cfg.AdminUserIDs,
+		IAM:                iamCli,
+		Candidates:         ce,
+		InternalLeaseTTL:   cfg.InternalLeaseTTL,
+		ReservationTTL:     cfg.ReservationTTL,
+		BenchmarkTolerance: cfg.BenchmarkTolerance,
+		Liveness:           cfg.Liveness,
+		VersionPolicy:      versionPolicy,
+	}
+
 	rpc, err := rpc.NewServer(rpcConfig, ds)
 	if err != nil {
 		return nil, err
 	}
 
-	ms, err := metrics.NewServer(cfg.MetricsAddr, cfg.Logger.WithField("system", "metrics-server"))
+	el, err := election.NewElector(
+		election.WithLogger(cfg.Logger.WithField("system", "election")),
+		election.WithDatastore(ds),
+		election.WithTTL(cfg.LeaderLeaseTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewCollector(cfg.Name, ds, el)
+
+	policies, err := manager.ParsePolicies(cfg.RemediationPolicies)
 	if err != nil {
 		return nil, err
 	}
 
-	mc := metrics.NewCollector(cfg.Name, ds)
+	var notifier manager.Notifier
+	if cfg.RemediationNotifyQueue != "" {
+		notifier, err = manager.NewMQNotifier(cfg.MQURI, cfg.Name, cfg.RemediationNotifyQueue)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	dm, err := manager.New(
 		manager.WithLogger(cfg.Logger.WithField("system", "datamanager")),
 		manager.WithDatastore(ds),
 		manager.WithEmitterServiceClient(cfg.EmitterRPCAddr),
+		manager.WithBenchmarkInterval(cfg.BenchmarkInterval),
+		manager.WithElector(el),
+		manager.WithLiveness(cfg.Liveness),
+		manager.WithPolicies(policies),
+		manager.WithNotifier(notifier),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	hc, err := newChecker(cfg, ds, rpc, dm, el)
+	if err != nil {
+		return nil, err
+	}
+
+	adminAPI, err := admin.NewAPI(
+		admin.WithLogger(cfg.Logger.WithField("system", "admin")),
+		admin.WithDatastore(ds),
+		admin.WithCandidates(ce),
+		admin.WithAuthenticator(rpc.AuthenticateAdmin),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := metrics.NewServer(cfg.MetricsAddr, cfg.Logger.WithField("system", "metrics-server"), hc, adminAPI)
+	if err != nil {
+		return nil, err
+	}
+
 	svc := &Service{
 		cfg: cfg,
 		rpc: rpc,
@@ -64,6 +141,8 @@ func NewService(cfg *Config) (*Service, error) {
 		mc:  mc,
 		ms:  ms,
 		dm:  dm,
+		el:  el,
+		hc:  hc,
 	}
 
 	return svc, nil
@@ -80,20 +159,50 @@ func (s *Service) Start(errCh chan error) {
 		errCh <- s.ms.Start()
 	}()
 
+	s.cfg.Logger.Info("starting leader election")
+	s.el.Start()
+
 	s.cfg.Logger.Info("starting metrics collector")
 	s.mc.Start()
 
 	s.cfg.Logger.Info("starting data manager")
 	s.dm.Start()
+
+	s.cfg.Logger.Info("starting health checker")
+	s.hc.Start()
 }
 
-func (s *Service) Stop() error {
-	err := s.mc.Stop()
-	if err != nil {
-		return err
+// Stop shuts the service down in dependency order: readiness fails and the
+// rpc server stops taking calls first, then the background jobs, the
+// leadership and the metrics server, which keeps answering probes until the
+// end. The context bounds the whole shutdown. Every step runs even if an
+// earlier one failed; every error is logged and the first one is returned.
+func (s *Service) Stop(ctx context.Context) error {
+	steps := []struct {
+		name string
+		stop func(context.Context) error
+	}{
+		{"rpc server", s.rpc.Stop},
+		{"health checker", s.hc.Stop},
+		{"data manager", s.dm.Stop},
+		{"metrics collector", s.mc.Stop},
+		{"leader election", s.el.Stop},
+		{"metrics server", s.ms.Stop},
 	}
 
-	s.dm.Stop()
+	s.cfg.Logger.Info("marking service as not ready")
+	s.hc.Shutdown()
+
+	var stopErr error
+	for _, step := range steps {
+		s.cfg.Logger.Infof("stopping %s", step.name)
+		if err := step.stop(ctx); err != nil {
+			s.cfg.Logger.WithError(err).Errorf("failed to stop %s", step.name)
+			if stopErr == nil {
+				stopErr = err
+			}
+		}
+	}
 
-	return nil
+	return stopErr
 }